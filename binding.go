@@ -0,0 +1,224 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+// Content-Type MIME of the most common data formats.
+const (
+	MIMEJSON              = "application/json"
+	MIMEHTML              = "text/html"
+	MIMEXML               = "application/xml"
+	MIMEXML2              = "text/xml"
+	MIMEPlain             = "text/plain"
+	MIMEPOSTForm          = "application/x-www-form-urlencoded"
+	MIMEMultipartPOSTForm = "multipart/form-data"
+	MIMEYAML              = "application/x-yaml"
+	MIMEMsgPack           = "application/msgpack"
+)
+
+var (
+	// ErrBindNilPointer is returned when the bind target is not a non-nil pointer.
+	ErrBindNilPointer = errors.New("bind target must be a non-nil pointer")
+	// ErrBindEmptyBody is returned when a body binding gets an empty request body.
+	ErrBindEmptyBody = errors.New("request body is empty")
+)
+
+// BindError describes a failure while decoding the request into a struct.
+type BindError struct {
+	// Binding is the name of the binding that failed, e.g. "json" or "form".
+	Binding string
+	// Field is the struct field (or json path) which could not be set, may be empty.
+	Field string
+	Err   error
+}
+
+func (e *BindError) Error() string {
+	if e.Field == "" {
+		return "klyn: " + e.Binding + " binding: " + e.Err.Error()
+	}
+	return "klyn: " + e.Binding + " binding: field '" + e.Field + "': " + e.Err.Error()
+}
+
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// Binding decodes data of the request, such as the json body, the query
+// string or the posted form, into a struct.
+type Binding interface {
+	Name() string
+	Bind(*http.Request, interface{}) error
+}
+
+// BindingUri decodes the path params matched by the router into a struct.
+type BindingUri interface {
+	Name() string
+	BindUri(Params, interface{}) error
+}
+
+// Builtin bindings. Struct fields are matched by the `json`, `form`, `uri`
+// and `header` tags respectively.
+var (
+	BindingJSON          Binding    = jsonBinding{}
+	BindingForm          Binding    = formBinding{}
+	BindingQuery         Binding    = queryBinding{}
+	BindingFormPost      Binding    = formPostBinding{}
+	BindingFormMultipart Binding    = formMultipartBinding{}
+	BindingHeader        Binding    = headerBinding{}
+	BindingURI           BindingUri = uriBinding{}
+)
+
+// bindingFor returns the binding matching the request method and content type.
+func bindingFor(method, contentType string) Binding {
+	if method == http.MethodGet {
+		return BindingForm
+	}
+
+	switch contentType {
+	case MIMEJSON:
+		return BindingJSON
+	case MIMEMultipartPOSTForm:
+		return BindingFormMultipart
+	default:
+		return BindingForm
+	}
+}
+
+// jsonBinding decodes with codec, Context sets it to Core.JSONCodec.
+type jsonBinding struct {
+	codec JSONCodec
+}
+
+func (jsonBinding) Name() string {
+	return "json"
+}
+
+func (b jsonBinding) Bind(req *http.Request, obj interface{}) error {
+	if req == nil || req.Body == nil {
+		return &BindError{Binding: b.Name(), Err: ErrBindEmptyBody}
+	}
+
+	return decodeJSON(codecOr(b.codec), req.Body, obj)
+}
+
+func decodeJSON(codec JSONCodec, r io.Reader, obj interface{}) error {
+	if err := codec.NewDecoder(r).Decode(obj); err != nil {
+		mapper, ok := codec.(JSONErrorMapper)
+		if !ok {
+			mapper = StdJSONCodec{}
+		}
+		field, cause := mapper.MapDecodeError(err)
+		return &BindError{Binding: "json", Field: field, Err: cause}
+	}
+
+	return nil
+}
+
+// formBinding parses multipart forms with maxMemory, Context sets it to
+// Core.MaxMultipartMemory.
+type formBinding struct {
+	maxMemory int64
+}
+
+func (formBinding) Name() string {
+	return "form"
+}
+
+func (b formBinding) Bind(req *http.Request, obj interface{}) error {
+	if err := req.ParseMultipartForm(multipartMemory(b.maxMemory)); err != nil && err != http.ErrNotMultipart {
+		return &BindError{Binding: b.Name(), Err: err}
+	}
+
+	return mapForm(b.Name(), obj, req.Form, "form")
+}
+
+func multipartMemory(maxMemory int64) int64 {
+	if maxMemory <= 0 {
+		return defaultMultipartMemory
+	}
+	return maxMemory
+}
+
+type queryBinding struct{}
+
+func (queryBinding) Name() string {
+	return "query"
+}
+
+func (b queryBinding) Bind(req *http.Request, obj interface{}) error {
+	return mapForm(b.Name(), obj, req.URL.Query(), "form")
+}
+
+type formPostBinding struct{}
+
+func (formPostBinding) Name() string {
+	return "form-urlencoded"
+}
+
+func (b formPostBinding) Bind(req *http.Request, obj interface{}) error {
+	if err := req.ParseForm(); err != nil {
+		return &BindError{Binding: b.Name(), Err: err}
+	}
+
+	return mapForm(b.Name(), obj, req.PostForm, "form")
+}
+
+type formMultipartBinding struct {
+	maxMemory int64
+}
+
+func (formMultipartBinding) Name() string {
+	return "multipart/form-data"
+}
+
+func (b formMultipartBinding) Bind(req *http.Request, obj interface{}) error {
+	if err := req.ParseMultipartForm(multipartMemory(b.maxMemory)); err != nil {
+		return &BindError{Binding: b.Name(), Err: err}
+	}
+
+	m := &formMapper{
+		binding: b.Name(),
+		tag:     "form",
+		values:  req.MultipartForm.Value,
+		files:   req.MultipartForm.File,
+	}
+	return m.mapTo(obj)
+}
+
+type headerBinding struct{}
+
+func (headerBinding) Name() string {
+	return "header"
+}
+
+func (b headerBinding) Bind(req *http.Request, obj interface{}) error {
+	m := &formMapper{
+		binding:   b.Name(),
+		tag:       "header",
+		values:    req.Header,
+		canonical: true,
+	}
+	return m.mapTo(obj)
+}
+
+type uriBinding struct{}
+
+func (uriBinding) Name() string {
+	return "uri"
+}
+
+func (b uriBinding) BindUri(params Params, obj interface{}) error {
+	values := make(map[string][]string, len(params))
+	for _, p := range params {
+		values[p.Key] = append(values[p.Key], p.Value)
+	}
+
+	return mapForm(b.Name(), obj, values, "uri")
+}
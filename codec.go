@@ -0,0 +1,140 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// JSONCodec is the json implementation used by Core to render and bind json.
+type JSONCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+	NewEncoder(w io.Writer) JSONEncoder
+	NewDecoder(r io.Reader) JSONDecoder
+}
+
+// JSONEncoder writes json values to an output stream.
+type JSONEncoder interface {
+	Encode(v interface{}) error
+	SetIndent(prefix, indent string)
+	SetEscapeHTML(on bool)
+}
+
+// JSONDecoder reads json values from an input stream.
+type JSONDecoder interface {
+	Decode(v interface{}) error
+}
+
+// JSONErrorMapper is implemented by a JSONCodec whose decode errors differ
+// from the ones of encoding/json, bindings use it to fill BindError.
+type JSONErrorMapper interface {
+	// MapDecodeError returns the field the decode error err is about, empty
+	// if unknown, and the error to report.
+	MapDecodeError(err error) (field string, cause error)
+}
+
+var (
+	_ JSONCodec = StdJSONCodec{}
+	_ JSONCodec = JSONIterCodec{}
+
+	_ JSONErrorMapper = StdJSONCodec{}
+	_ JSONErrorMapper = JSONIterCodec{}
+)
+
+// StdJSONCodec is the JSONCodec of encoding/json, the default one.
+type StdJSONCodec struct{}
+
+func (StdJSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (StdJSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (StdJSONCodec) NewEncoder(w io.Writer) JSONEncoder {
+	return json.NewEncoder(w)
+}
+
+func (StdJSONCodec) NewDecoder(r io.Reader) JSONDecoder {
+	return json.NewDecoder(r)
+}
+
+func (StdJSONCodec) MapDecodeError(err error) (string, error) {
+	var typeErr *json.UnmarshalTypeError
+	var invalidErr *json.InvalidUnmarshalError
+	switch {
+	case err == io.EOF:
+		return "", ErrBindEmptyBody
+	case errors.As(err, &invalidErr):
+		return "", ErrBindNilPointer
+	case errors.As(err, &typeErr):
+		return typeErr.Field, err
+	}
+	return "", err
+}
+
+// JSONIterCodec is the JSONCodec of json-iterator, configured to behave like
+// encoding/json.
+type JSONIterCodec struct{}
+
+var jsoniterAPI = jsoniter.ConfigCompatibleWithStandardLibrary
+
+func (JSONIterCodec) Marshal(v interface{}) ([]byte, error) {
+	return jsoniterAPI.Marshal(v)
+}
+
+func (JSONIterCodec) Unmarshal(data []byte, v interface{}) error {
+	return jsoniterAPI.Unmarshal(data, v)
+}
+
+func (JSONIterCodec) NewEncoder(w io.Writer) JSONEncoder {
+	return jsoniterAPI.NewEncoder(w)
+}
+
+func (JSONIterCodec) NewDecoder(r io.Reader) JSONDecoder {
+	return jsoniterAPI.NewDecoder(r)
+}
+
+// MapDecodeError reads the field from the message of err, json-iterator
+// prefixes it with the struct fields being decoded, e.g.
+// "main.User.Age: readUint64: unexpected character".
+func (JSONIterCodec) MapDecodeError(err error) (string, error) {
+	if err == io.EOF {
+		return "", ErrBindEmptyBody
+	}
+
+	msg := err.Error()
+	if strings.HasPrefix(msg, "ReadVal: can only unmarshal into pointer") ||
+		strings.HasPrefix(msg, "ReadVal: can not read into nil pointer") {
+		return "", ErrBindNilPointer
+	}
+
+	var fields []string
+	for {
+		i := strings.Index(msg, ": ")
+		dot := strings.LastIndexByte(msg[:i+1], '.')
+		if i < 0 || dot < 0 || strings.ContainsAny(msg[:i], " \t") {
+			break
+		}
+		fields = append(fields, msg[dot+1:i])
+		msg = msg[i+2:]
+	}
+	return strings.Join(fields, "."), err
+}
+
+// codecOr returns codec, or the default codec if it is nil.
+func codecOr(codec JSONCodec) JSONCodec {
+	if codec == nil {
+		return StdJSONCodec{}
+	}
+	return codec
+}
@@ -0,0 +1,82 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var testCodecs = map[string]JSONCodec{"std": StdJSONCodec{}, "jsoniter": JSONIterCodec{}}
+
+func TestJSONRenderNoTrailingNewline(t *testing.T) {
+	for name, codec := range testCodecs {
+		w := httptest.NewRecorder()
+		if err := (JSONRender{Codec: codec, Data: K{"a": 1}}).Render(w); err != nil {
+			t.Fatal(err)
+		}
+		if body := w.Body.String(); body != `{"a":1}` {
+			t.Errorf("%s: body %q, want {\"a\":1}", name, body)
+		}
+
+		w = httptest.NewRecorder()
+		if err := (IndentedJSONRender{Codec: codec, Data: []int{1}}).Render(w); err != nil {
+			t.Fatal(err)
+		}
+		if body := w.Body.String(); body != "[\n    1\n]" {
+			t.Errorf("%s: indented body %q", name, body)
+		}
+	}
+}
+
+func TestDecodeJSONErrors(t *testing.T) {
+	type inner struct {
+		N int `json:"n"`
+	}
+	type user struct {
+		Age   int   `json:"age"`
+		Inner inner `json:"inner"`
+	}
+
+	tests := []struct {
+		body      string
+		std, iter string // expected field
+		err       error
+	}{
+		{body: `{"age":"x"}`, std: "age", iter: "Age"},
+		{body: `{"inner":{"n":"x"}}`, std: "inner.n", iter: "Inner.N"},
+		{body: ``, err: ErrBindEmptyBody},
+	}
+	for name, codec := range testCodecs {
+		for _, tt := range tests {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			err := jsonBinding{codec: codec}.Bind(req, &user{})
+
+			var bindErr *BindError
+			if !errors.As(err, &bindErr) {
+				t.Fatalf("%s %q: got %v, want a BindError", name, tt.body, err)
+			}
+			field := tt.std
+			if name == "jsoniter" {
+				field = tt.iter
+			}
+			if bindErr.Field != field {
+				t.Errorf("%s %q: field %q, want %q", name, tt.body, bindErr.Field, field)
+			}
+			if tt.err != nil && bindErr.Err != tt.err {
+				t.Errorf("%s %q: error %v, want %v", name, tt.body, bindErr.Err, tt.err)
+			}
+		}
+
+		var nilUser *user
+		err := jsonBinding{codec: codec}.Bind(httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{}")), nilUser)
+		if !errors.Is(err, ErrBindNilPointer) {
+			t.Errorf("%s: bind into nil pointer got %v, want ErrBindNilPointer", name, err)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const defaultCompressMinLength = 1024
+
+// CompressWriter is an encoder of a content coding. gzip.Writer, zlib.Writer
+// and the writers of the common brotli packages implement it.
+type CompressWriter interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// Compressor pools the encoders of a content coding.
+type Compressor struct {
+	encoding string
+	pool     sync.Pool
+}
+
+// NewCompressor returns a Compressor of the content coding encoding, e.g.
+// "br", whose encoders are created by newWriter.
+func NewCompressor(encoding string, newWriter func(w io.Writer) CompressWriter) *Compressor {
+	assert1(encoding != "", "content coding can not be empty")
+	assert1(newWriter != nil, "compress writer factory can not be nil")
+
+	cp := &Compressor{encoding: encoding}
+	cp.pool.New = func() interface{} {
+		return newWriter(io.Discard)
+	}
+	return cp
+}
+
+// GzipCompressor returns a gzip Compressor of the given compression level.
+func GzipCompressor(level int) *Compressor {
+	return NewCompressor("gzip", func(w io.Writer) CompressWriter {
+		gz, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			panic(err)
+		}
+		return gz
+	})
+}
+
+// DeflateCompressor returns a deflate (zlib) Compressor of the given compression level.
+func DeflateCompressor(level int) *Compressor {
+	return NewCompressor("deflate", func(w io.Writer) CompressWriter {
+		zw, err := zlib.NewWriterLevel(w, level)
+		if err != nil {
+			panic(err)
+		}
+		return zw
+	})
+}
+
+// Encoding returns the content coding of cp.
+func (cp *Compressor) Encoding() string {
+	return cp.encoding
+}
+
+func (cp *Compressor) get(w io.Writer) CompressWriter {
+	cw := cp.pool.Get().(CompressWriter)
+	cw.Reset(w)
+	return cw
+}
+
+func (cp *Compressor) put(cw CompressWriter) {
+	cw.Reset(io.Discard)
+	cp.pool.Put(cw)
+}
+
+// CompressConfig configures CompressWithConfig.
+type CompressConfig struct {
+	// Compressors in order of preference, gzip and deflate by default.
+	Compressors []*Compressor
+	// MinLength is the body size from which responses are compressed,
+	// 1024 by default.
+	MinLength int
+	// SkipContentTypes are Content-Type prefixes never compressed, in
+	// addition to already compressed formats like images and archives.
+	SkipContentTypes []string
+}
+
+// incompressible are Content-Type prefixes of already compressed formats.
+var incompressible = []string{
+	"image/png", "image/jpeg", "image/gif", "image/webp", "image/avif",
+	"video/", "audio/", "font/woff",
+	"application/zip", "application/gzip", "application/x-gzip",
+	"application/x-brotli", "application/x-7z-compressed", "application/x-rar-compressed",
+	"application/msgpack",
+}
+
+// Compress returns a middleware compressing responses with gzip or deflate,
+// see CompressWithConfig.
+func Compress() HandlerFunc {
+	return CompressWithConfig(CompressConfig{})
+}
+
+// CompressWithConfig returns a middleware compressing the response body with
+// the preferred encoding accepted by the client. Bodies smaller than
+// MinLength, partial responses and already compressed content are sent as is.
+// Upgrade requests are never wrapped, so the connection can be hijacked.
+func CompressWithConfig(cfg CompressConfig) HandlerFunc {
+	if len(cfg.Compressors) == 0 {
+		cfg.Compressors = []*Compressor{
+			GzipCompressor(gzip.DefaultCompression),
+			DeflateCompressor(zlib.DefaultCompression),
+		}
+	}
+	if cfg.MinLength <= 0 {
+		cfg.MinLength = defaultCompressMinLength
+	}
+	skip := append(append([]string(nil), incompressible...), cfg.SkipContentTypes...)
+
+	encodings := make([]string, len(cfg.Compressors))
+	for i, cp := range cfg.Compressors {
+		encodings[i] = cp.Encoding()
+	}
+
+	return func(c *Context) {
+		req := c.Request
+		if req.Method == http.MethodHead || req.Header.Get("Upgrade") != "" {
+			c.Next()
+			return
+		}
+
+		addVary(c.Writer.Header(), "Accept-Encoding")
+		encoding := negotiateEncoding(req.Header.Get("Accept-Encoding"), encodings)
+		if encoding == "" {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{
+			ResponseWriter: c.Writer,
+			compressor:     cfg.Compressors[indexOf(encodings, encoding)],
+			minLength:      cfg.MinLength,
+			skip:           skip,
+			size:           noWritten,
+		}
+		c.Writer = w
+		defer func() {
+			c.Writer = w.ResponseWriter
+			w.close()
+		}()
+
+		c.Next()
+		w.finish()
+	}
+}
+
+const (
+	compressUndecided = iota
+	compressOn
+	compressOff
+)
+
+// compressWriter buffers the body until it is known whether it is worth
+// compressing, then either encodes or passes through the writes.
+type compressWriter struct {
+	ResponseWriter
+
+	compressor *Compressor
+	minLength  int
+	skip       []string
+
+	state   int
+	buf     []byte
+	encoder CompressWriter
+	size    int // uncompressed bytes written by the handler
+}
+
+var _ ResponseWriter = &compressWriter{}
+
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if w.size == noWritten {
+		w.size = 0
+	}
+	w.size += len(data)
+
+	switch w.state {
+	case compressOn:
+		return w.encoder.Write(data)
+	case compressOff:
+		return w.ResponseWriter.Write(data)
+	}
+
+	w.buf = append(w.buf, data...)
+	if len(w.buf) < w.minLength {
+		return len(data), nil
+	}
+
+	w.decide()
+	if err := w.flushBuffer(); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressWriter) WriteHeaderNow() {
+	if w.size == noWritten {
+		w.size = 0
+	}
+	if w.state == compressUndecided && len(w.buf) == 0 {
+		// headers without a body, e.g. AbortWithStatus
+		w.state = compressOff
+	}
+	if w.state == compressOff {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *compressWriter) Size() int {
+	return w.size
+}
+
+func (w *compressWriter) Written() bool {
+	return w.size != noWritten
+}
+
+// Flush sends the buffered body, streamed responses are compressed
+// regardless of their size.
+func (w *compressWriter) Flush() {
+	if w.state == compressUndecided {
+		w.decide()
+		if err := w.flushBuffer(); err != nil {
+			return
+		}
+	}
+	if w.state == compressOn {
+		w.encoder.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Hijack implements the http.Hijacker interface.
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if w.state == compressUndecided {
+		w.state = compressOff
+	}
+	return w.ResponseWriter.Hijack()
+}
+
+// decide switches to compressing if the response allows it.
+func (w *compressWriter) decide() {
+	header := w.Header()
+	if header.Get("Content-Type") == "" && len(w.buf) > 0 {
+		// the encoded body can not be sniffed by net/http
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+
+	status := w.Status()
+	if header.Get("Content-Encoding") != "" || status == http.StatusPartialContent ||
+		!bodyAllowedForStatus(status) || hasPrefixIn(header.Get("Content-Type"), w.skip) {
+		w.state = compressOff
+		return
+	}
+
+	w.state = compressOn
+	header.Set("Content-Encoding", w.compressor.Encoding())
+	header.Del("Content-Length")
+	if etag := header.Get("ETag"); strings.HasPrefix(etag, `"`) {
+		// the encoded body is not byte-for-byte the same representation
+		header.Set("ETag", "W/"+etag)
+	}
+	w.ResponseWriter.WriteHeaderNow()
+	w.encoder = w.compressor.get(w.ResponseWriter)
+}
+
+func (w *compressWriter) flushBuffer() (err error) {
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	if w.state == compressOn {
+		_, err = w.encoder.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+	return
+}
+
+// finish writes the body left in the buffer once the handlers are done.
+func (w *compressWriter) finish() {
+	if w.state == compressUndecided {
+		w.state = compressOff
+		if len(w.buf) > 0 {
+			w.Header().Set("Content-Length", strconv.Itoa(len(w.buf)))
+		}
+		w.flushBuffer()
+	}
+}
+
+// close ends the encoded stream. If the handlers panicked the buffered body
+// is dropped, so the recovery can still respond.
+func (w *compressWriter) close() {
+	w.buf = nil
+	if w.encoder != nil {
+		w.encoder.Close()
+		w.compressor.put(w.encoder)
+		w.encoder = nil
+	}
+}
+
+// negotiateEncoding returns the offered content coding with the highest
+// quality in the Accept-Encoding header, or "" if none is acceptable.
+func negotiateEncoding(header string, offered []string) string {
+	if header == "" {
+		return ""
+	}
+
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(params[0]))
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		accepted[coding] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range offered {
+		q, ok := accepted[encoding]
+		if !ok {
+			q = accepted["*"]
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// addVary adds value to the Vary header unless it is listed already.
+func addVary(header http.Header, value string) {
+	for _, v := range header.Values("Vary") {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), value) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", value)
+}
+
+func hasPrefixIn(s string, prefixes []string) bool {
+	s = strings.ToLower(s)
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,137 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	large := strings.Repeat("klyn ", 400)
+	core := New()
+	core.UseMiddleware(CompressWithConfig(CompressConfig{SkipContentTypes: []string{"text/csv"}}))
+	core.GET("/large", func(c *Context) {
+		c.Writer.Header().Set("Content-Length", "2000")
+		c.Writer.Header().Set("ETag", `"v1"`)
+		c.String(http.StatusOK, large)
+	})
+	core.GET("/small", func(c *Context) { c.String(http.StatusOK, "small") })
+	core.GET("/png", func(c *Context) { c.Data(http.StatusOK, "image/png", []byte(large)) })
+	core.GET("/csv", func(c *Context) { c.Data(http.StatusOK, "text/csv", []byte(large)) })
+	core.GET("/partial", func(c *Context) { c.String(http.StatusPartialContent, large) })
+	core.GET("/empty", func(c *Context) { c.Status(http.StatusNoContent) })
+	core.HEAD("/large", func(c *Context) {
+		c.Writer.Header().Set("Content-Length", "2000")
+		c.Status(http.StatusOK)
+	})
+
+	tests := []struct {
+		method, path, accept string
+		encoding             string // expected Content-Encoding
+		contentLength        string
+		vary                 bool
+	}{
+		{http.MethodGet, "/large", "gzip", "gzip", "", true},
+		{http.MethodGet, "/large", "deflate, gzip;q=0.5", "deflate", "", true},
+		{http.MethodGet, "/large", "", "", "2000", true},
+		{http.MethodGet, "/large", "br", "", "2000", true},
+		{http.MethodGet, "/small", "gzip", "", "5", true},
+		{http.MethodGet, "/png", "gzip", "", "", true},
+		{http.MethodGet, "/csv", "gzip", "", "", true},
+		{http.MethodGet, "/partial", "gzip", "", "", true},
+		{http.MethodGet, "/empty", "gzip", "", "", true},
+		{http.MethodHead, "/large", "gzip", "", "2000", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept-Encoding", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		core.ServeHTTP(w, req)
+		header := w.Header()
+		name := tt.method + " " + tt.path + " " + tt.accept
+
+		if got := header.Get("Content-Encoding"); got != tt.encoding {
+			t.Errorf("%s: Content-Encoding %q, want %q", name, got, tt.encoding)
+		}
+		if got := header.Get("Content-Length"); got != tt.contentLength {
+			t.Errorf("%s: Content-Length %q, want %q", name, got, tt.contentLength)
+		}
+		if got := header.Get("Vary") == "Accept-Encoding"; got != tt.vary {
+			t.Errorf("%s: Vary %q, want Accept-Encoding %v", name, header.Get("Vary"), tt.vary)
+		}
+
+		body := w.Body.String()
+		switch tt.encoding {
+		case "gzip":
+			zr, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, _ := io.ReadAll(zr)
+			body = string(b)
+		case "deflate":
+			zr, err := zlib.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, _ := io.ReadAll(zr)
+			body = string(b)
+		}
+		if tt.encoding != "" && (body != large || header.Get("ETag") != `W/"v1"`) {
+			t.Errorf("%s: decoded %d bytes with ETag %q, want %d bytes and a weak ETag",
+				name, len(body), header.Get("ETag"), len(large))
+		}
+	}
+}
+
+func TestCompressHijack(t *testing.T) {
+	core := New()
+	core.UseMiddleware(Compress())
+	core.GET("/", func(c *Context) {
+		conn, rw, err := c.Writer.Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		rw.Flush()
+	})
+	srv := httptest.NewServer(core)
+	defer srv.Close()
+
+	for _, upgrade := range []string{"", "websocket"} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		if upgrade != "" {
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", upgrade)
+		}
+		conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Write(conn)
+		resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+		if err != nil {
+			t.Fatalf("upgrade %q: %v", upgrade, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		conn.Close()
+		if string(body) != "hijacked" {
+			t.Errorf("upgrade %q: body %q, want hijacked", upgrade, body)
+		}
+	}
+}
@@ -5,17 +5,24 @@
 package klyn
 
 import (
+	"crypto/x509"
+	"errors"
+	"log"
 	"math"
 	"net"
 	"net/http"
+	"net/url"
 	"strings"
-
-	"encoding/json"
 )
 
 const (
-	abortIndex  int8 = math.MaxInt8 / 2
-	jsonContent      = "application/json; charset=utf-8"
+	abortIndex        int8 = math.MaxInt8 / 2
+	jsonContent            = "application/json; charset=utf-8"
+	javascriptContent      = "application/javascript; charset=utf-8"
+	xmlContent             = "application/xml; charset=utf-8"
+	yamlContent            = "application/x-yaml; charset=utf-8"
+	plainContent           = "text/plain; charset=utf-8"
+	htmlContent            = "text/html; charset=utf-8"
 )
 
 // Context is context of http request
@@ -25,18 +32,30 @@ type Context struct {
 	Writer    ResponseWriter
 	Params    Params
 
+	// Errors are the errors reported by the handlers, see Context.Error.
+	Errors ErrorList
+
 	handlers  HandlersChain
 	core      *Core
 	index     int8
 	cachePool map[string]interface{} // memory cache pool for context
+
+	queryCache url.Values // parsed URL.RawQuery, see Context.Query
+	formCache  url.Values // parsed body form, see Context.PostForm
+
+	describe *handlerDesc // set to ask a WrapErr or Typed handler for its description
 }
 
 // reset context
 func (c *Context) reset() {
 	c.Writer = &c.memWriter
+	c.Params = c.Params[:0]
+	c.Errors = c.Errors[:0]
 	c.handlers = nil
 	c.index = -1
 	c.cachePool = nil
+	c.queryCache = nil
+	c.formCache = nil
 }
 
 // Copy returns a copy of the current context that can be safely used outside the request's scope.
@@ -45,6 +64,8 @@ func (c *Context) Copy() *Context {
 	var cp = *c
 	cp.memWriter.ResponseWriter = nil
 	cp.Writer = &cp.memWriter
+	cp.Params = append(Params(nil), c.Params...)
+	cp.Errors = append(ErrorList(nil), c.Errors...)
 	cp.index = abortIndex
 	cp.handlers = nil
 	return &cp
@@ -55,7 +76,7 @@ func (c *Context) Handler() HandlerFunc {
 }
 
 func (c *Context) HandlerName() string {
-	return nameOfFunction(c.handlers.Last())
+	return handlerName(c.handlers.Last())
 }
 
 func (c *Context) Next() {
@@ -80,13 +101,79 @@ func (c *Context) AbortWithJSON(v interface{}) {
 	c.JSON(http.StatusOK, v)
 }
 
-// JSON - write response as json type
+// JSON - write response as json type, encoded by Core.JSONCodec
 func (c *Context) JSON(code int, v interface{}) {
+	c.Render(code, JSONRender{Codec: c.core.JSONCodec, Data: v})
+}
+
+// Render writes the status code and the body rendered by r. If rendering
+// fails before anything was written the response becomes 500.
+func (c *Context) Render(code int, r Render) {
 	c.Status(code)
-	c.jsonContent()
-	body, _ := json.Marshal(v)
-	c.Writer.Write(body)
-	return
+
+	if !bodyAllowedForStatus(code) {
+		r.WriteContentType(c.Writer)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+
+	r.WriteContentType(c.Writer)
+	if err := r.Render(c.Writer); err != nil {
+		log.Printf("[WARNING] render %s failed: %v\n", c.Request.URL.Path, err)
+		c.Error(err).SetType(ErrorTypeRender)
+		if !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Type")
+			c.Abort()
+			c.Status(http.StatusInternalServerError)
+		}
+	}
+}
+
+// IndentedJSON - write response as indented json
+func (c *Context) IndentedJSON(code int, v interface{}) {
+	c.Render(code, IndentedJSONRender{Codec: c.core.JSONCodec, Data: v})
+}
+
+// SecureJSON - write response as json, json arrays are prefixed by
+// Core.SecureJSONPrefix to prevent json hijacking
+func (c *Context) SecureJSON(code int, v interface{}) {
+	c.Render(code, SecureJSONRender{Codec: c.core.JSONCodec, Prefix: c.core.SecureJSONPrefix, Data: v})
+}
+
+// JSONP - write response as json wrapped into the function named by the
+// `callback` query param
+func (c *Context) JSONP(code int, v interface{}) {
+	c.Render(code, JSONPRender{
+		Codec:    c.core.JSONCodec,
+		Callback: c.Query("callback"),
+		Data:     v,
+	})
+}
+
+// XML - write response as xml
+func (c *Context) XML(code int, v interface{}) {
+	c.Render(code, XMLRender{Data: v})
+}
+
+// YAML - write response as yaml
+func (c *Context) YAML(code int, v interface{}) {
+	c.Render(code, YAMLRender{Data: v})
+}
+
+// MsgPack - write response as MessagePack
+func (c *Context) MsgPack(code int, v interface{}) {
+	c.Render(code, MsgPackRender{Data: v})
+}
+
+// String - write response as plain text formatted by fmt.Sprintf
+func (c *Context) String(code int, format string, values ...interface{}) {
+	c.Render(code, TextRender{Format: format, Data: values})
+}
+
+// HTML - write response by executing the template loaded by Core.LoadHTMLGlob,
+// Core.LoadHTMLFiles or Core.SetHTMLTemplate
+func (c *Context) HTML(code int, name string, v interface{}) {
+	c.Render(code, HTMLRender{Template: c.core.htmlTemplate, Name: name, Data: v})
 }
 
 // Data - write response as contentType
@@ -112,11 +199,6 @@ func (c *Context) Status(code int) {
 	c.memWriter.WriteHeader(code)
 }
 
-// write json content type to header
-func (c *Context) jsonContent() {
-	c.Writer.Header().Set("Content-Type", jsonContent)
-}
-
 // Set - set key-value to Context.cachePool
 func (c *Context) Set(key string, value interface{}) {
 	if c.cachePool == nil {
@@ -187,10 +269,10 @@ func (c *Context) GetBool(key string) (b bool) {
 // ClientIP get client ip
 func (c *Context) ClientIP() string {
 	if c.core.ForwardByClientIP {
-		clientIP := c.requestHeader("X-Forwarded-For")
+		clientIP := c.GetHeader("X-Forwarded-For")
 		clientIP = strings.TrimSpace(strings.Split(clientIP, ",")[0])
 		if clientIP == "" {
-			clientIP = strings.TrimSpace(c.requestHeader("X-Real-Ip"))
+			clientIP = strings.TrimSpace(c.GetHeader("X-Real-Ip"))
 		}
 		if clientIP != "" {
 			return clientIP
@@ -207,6 +289,265 @@ func (c *Context) ClientIP() string {
 /*
  * Request
  */
-func (c *Context) requestHeader(key string) string {
+
+// GetHeader returns the first value of the request header key.
+func (c *Context) GetHeader(key string) string {
 	return c.Request.Header.Get(key)
 }
+
+// Cookie returns the value of the named request cookie with %XX escapes
+// decoded, a '+' is kept as is, or http.ErrNoCookie if there is none.
+func (c *Context) Cookie(name string) (string, error) {
+	cookie, err := c.Request.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	return url.PathUnescape(cookie.Value)
+}
+
+// Query returns the first value of the URL query key, or "" if there is
+// none, e.g. c.Query("id") is "1" for /user?id=1.
+func (c *Context) Query(key string) string {
+	value, _ := c.GetQuery(key)
+	return value
+}
+
+// DefaultQuery returns the first value of the URL query key, or defaultValue
+// if the key is absent.
+func (c *Context) DefaultQuery(key, defaultValue string) string {
+	if value, ok := c.GetQuery(key); ok {
+		return value
+	}
+	return defaultValue
+}
+
+// GetQuery returns the first value of the URL query key and whether the key
+// is present, e.g. ("", true) for /user?id=.
+func (c *Context) GetQuery(key string) (string, bool) {
+	if values, ok := c.GetQueryArray(key); ok {
+		return values[0], true
+	}
+	return "", false
+}
+
+// QueryArray returns the values of the URL query key.
+func (c *Context) QueryArray(key string) []string {
+	values, _ := c.GetQueryArray(key)
+	return values
+}
+
+// GetQueryArray returns the values of the URL query key and whether the key
+// is present.
+func (c *Context) GetQueryArray(key string) ([]string, bool) {
+	c.initQueryCache()
+	values, ok := c.queryCache[key]
+	return values, ok && len(values) > 0
+}
+
+// QueryMap returns the URL query keys of the form key[k]=v as map, e.g.
+// {"a": "1", "b": "2"} for c.QueryMap("ids") and /?ids[a]=1&ids[b]=2.
+func (c *Context) QueryMap(key string) map[string]string {
+	dicts, _ := c.GetQueryMap(key)
+	return dicts
+}
+
+// GetQueryMap returns the URL query map of key and whether there was at
+// least one entry, see QueryMap.
+func (c *Context) GetQueryMap(key string) (map[string]string, bool) {
+	c.initQueryCache()
+	return valuesMap(c.queryCache, key)
+}
+
+// PostForm returns the first value of the urlencoded or multipart form key
+// of the request body, or "" if there is none.
+func (c *Context) PostForm(key string) string {
+	value, _ := c.GetPostForm(key)
+	return value
+}
+
+// DefaultPostForm returns the first value of the body form key, or
+// defaultValue if the key is absent.
+func (c *Context) DefaultPostForm(key, defaultValue string) string {
+	if value, ok := c.GetPostForm(key); ok {
+		return value
+	}
+	return defaultValue
+}
+
+// GetPostForm returns the first value of the body form key and whether the
+// key is present.
+func (c *Context) GetPostForm(key string) (string, bool) {
+	if values, ok := c.GetPostFormArray(key); ok {
+		return values[0], true
+	}
+	return "", false
+}
+
+// PostFormArray returns the values of the body form key.
+func (c *Context) PostFormArray(key string) []string {
+	values, _ := c.GetPostFormArray(key)
+	return values
+}
+
+// GetPostFormArray returns the values of the body form key and whether the
+// key is present.
+func (c *Context) GetPostFormArray(key string) ([]string, bool) {
+	c.initFormCache()
+	values, ok := c.formCache[key]
+	return values, ok && len(values) > 0
+}
+
+// PostFormMap returns the body form keys of the form key[k]=v as map, see
+// QueryMap.
+func (c *Context) PostFormMap(key string) map[string]string {
+	dicts, _ := c.GetPostFormMap(key)
+	return dicts
+}
+
+// GetPostFormMap returns the body form map of key and whether there was at
+// least one entry.
+func (c *Context) GetPostFormMap(key string) (map[string]string, bool) {
+	c.initFormCache()
+	return valuesMap(c.formCache, key)
+}
+
+func (c *Context) initQueryCache() {
+	if c.queryCache == nil {
+		if c.Request != nil && c.Request.URL != nil {
+			c.queryCache = c.Request.URL.Query()
+		} else {
+			c.queryCache = url.Values{}
+		}
+	}
+}
+
+func (c *Context) initFormCache() {
+	if c.formCache == nil {
+		req := c.Request
+		if err := req.ParseMultipartForm(multipartMemory(c.core.MaxMultipartMemory)); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			log.Printf("[WARNING] parse form of %s failed: %v\n", req.URL.Path, err)
+		}
+		c.formCache = req.PostForm
+		if c.formCache == nil {
+			c.formCache = url.Values{}
+		}
+	}
+}
+
+// valuesMap collects the values of the keys key[k] into a map keyed by k.
+func valuesMap(values url.Values, key string) (map[string]string, bool) {
+	dicts := make(map[string]string)
+	exist := false
+	for k, v := range values {
+		if i := strings.IndexByte(k, '['); i >= 1 && k[:i] == key {
+			if j := strings.IndexByte(k[i+1:], ']'); j >= 1 && len(v) > 0 {
+				exist = true
+				dicts[k[i+1:][:j]] = v[0]
+			}
+		}
+	}
+	return dicts, exist
+}
+
+// PeerCertificate returns the verified client certificate of a mutual TLS
+// connection, or nil if the client presented none.
+func (c *Context) PeerCertificate() *x509.Certificate {
+	if c.Request.TLS == nil || len(c.Request.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+
+	return c.Request.TLS.PeerCertificates[0]
+}
+
+// ContentType returns the Content-Type header of the request without parameters.
+func (c *Context) ContentType() string {
+	return filterFlags(c.GetHeader("Content-Type"))
+}
+
+/*
+ * Binding
+ */
+
+// Bind picks a binding by the request method and Content-Type and decodes the
+// request into obj. On failure the chain is aborted with 400.
+func (c *Context) Bind(obj interface{}) error {
+	return c.BindWith(obj, bindingFor(c.Request.Method, c.ContentType()))
+}
+
+// BindWith decodes the request into obj using the given binding. On failure
+// the error is reported by Context.Error and the chain is aborted with 400,
+// or 413 if the body exceeds its limits.
+func (c *Context) BindWith(obj interface{}, b Binding) error {
+	if err := c.ShouldBindWith(obj, b); err != nil {
+		c.Error(err).SetType(ErrorTypeBind)
+		c.Abort()
+		if errors.Is(err, ErrBodyTooLarge) {
+			c.Status(http.StatusRequestEntityTooLarge)
+		} else {
+			c.Status(http.StatusBadRequest)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// ShouldBind is like Bind but leaves the response untouched on failure.
+func (c *Context) ShouldBind(obj interface{}) error {
+	return c.ShouldBindWith(obj, bindingFor(c.Request.Method, c.ContentType()))
+}
+
+// ShouldBindWith decodes the request into obj using the given binding and
+// validates the result with Core.Validator.
+func (c *Context) ShouldBindWith(obj interface{}, b Binding) error {
+	if err := c.configure(b).Bind(c.Request, obj); err != nil {
+		return err
+	}
+
+	return c.validate(obj)
+}
+
+// ShouldBindJSON - shortcut of c.ShouldBindWith(obj, BindingJSON)
+func (c *Context) ShouldBindJSON(obj interface{}) error {
+	return c.ShouldBindWith(obj, BindingJSON)
+}
+
+// ShouldBindQuery - shortcut of c.ShouldBindWith(obj, BindingQuery)
+func (c *Context) ShouldBindQuery(obj interface{}) error {
+	return c.ShouldBindWith(obj, BindingQuery)
+}
+
+// ShouldBindHeader - shortcut of c.ShouldBindWith(obj, BindingHeader)
+func (c *Context) ShouldBindHeader(obj interface{}) error {
+	return c.ShouldBindWith(obj, BindingHeader)
+}
+
+// ShouldBindUri decodes the path params into obj by the `uri` tag.
+func (c *Context) ShouldBindUri(obj interface{}) error {
+	if err := BindingURI.BindUri(c.Params, obj); err != nil {
+		return err
+	}
+
+	return c.validate(obj)
+}
+
+// configure applies the settings of Core to the builtin bindings.
+func (c *Context) configure(b Binding) Binding {
+	switch b.(type) {
+	case jsonBinding:
+		return jsonBinding{codec: c.core.JSONCodec}
+	case formBinding:
+		return formBinding{maxMemory: c.core.MaxMultipartMemory}
+	case formMultipartBinding:
+		return formMultipartBinding{maxMemory: c.core.MaxMultipartMemory}
+	}
+	return b
+}
+
+func (c *Context) validate(obj interface{}) error {
+	if c.core.Validator == nil {
+		return nil
+	}
+
+	return c.core.Validator.ValidateStruct(obj)
+}
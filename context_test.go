@@ -0,0 +1,183 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAbortWithStatusKeepsHeadersOpen(t *testing.T) {
+	core := New()
+	core.UseMiddleware(func(c *Context) {
+		c.AbortWithStatus(http.StatusUnauthorized)
+		c.Writer.Header().Set("WWW-Authenticate", `Basic realm="klyn"`)
+		c.Writer.Write([]byte("denied"))
+	})
+	core.GET("/", func(c *Context) { t.Error("handler ran after abort") })
+
+	w := httptest.NewRecorder()
+	core.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status %d, want 401", w.Code)
+	}
+	if got := w.Header().Get("WWW-Authenticate"); got == "" {
+		t.Error("header set after AbortWithStatus is missing")
+	}
+	if w.Body.String() != "denied" {
+		t.Errorf("body %q, want denied", w.Body.String())
+	}
+}
+
+func TestCopyKeepsParams(t *testing.T) {
+	c := &Context{Params: Params{{Key: "id", Value: "1"}}}
+	cp := c.Copy()
+
+	// the pooled context is reused by the next request
+	c.reset()
+	c.Params = append(c.Params, Param{Key: "id", Value: "2"})
+
+	if id := cp.Params.ByName("id"); id != "1" {
+		t.Errorf("copied param id = %q, want 1", id)
+	}
+}
+
+func TestContextCookie(t *testing.T) {
+	tests := []struct {
+		raw, value string
+		err        error
+	}{
+		{"", "", http.ErrNoCookie},
+		{"a+b", "a+b", nil},
+		{"a%20b%2Bc", "a b+c", nil},
+		{"%zz", "", errors.New("invalid URL escape")},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.raw != "" {
+			req.AddCookie(&http.Cookie{Name: "session", Value: tt.raw})
+		}
+		c := &Context{Request: req}
+
+		value, err := c.Cookie("session")
+		if value != tt.value || (err == nil) != (tt.err == nil) ||
+			err != nil && !strings.Contains(err.Error(), tt.err.Error()) {
+			t.Errorf("cookie %q: got (%q, %v), want (%q, %v)", tt.raw, value, err, tt.value, tt.err)
+		}
+	}
+}
+
+func TestContextQuery(t *testing.T) {
+	c := &Context{Request: httptest.NewRequest(http.MethodGet, "/?id=1&id=2&empty=&ids[a]=x&ids[b]=y&ids[]=z&idsx[c]=w", nil)}
+
+	if got := c.Query("id"); got != "1" {
+		t.Errorf("Query(id) = %q, want 1", got)
+	}
+	if got, ok := c.GetQuery("empty"); got != "" || !ok {
+		t.Errorf("GetQuery(empty) = (%q, %v), want (\"\", true)", got, ok)
+	}
+	if got, ok := c.GetQuery("missing"); got != "" || ok {
+		t.Errorf("GetQuery(missing) = (%q, %v), want (\"\", false)", got, ok)
+	}
+	if got := c.DefaultQuery("empty", "d"); got != "" {
+		t.Errorf("DefaultQuery(empty) = %q, want \"\"", got)
+	}
+	if got := c.DefaultQuery("missing", "d"); got != "d" {
+		t.Errorf("DefaultQuery(missing) = %q, want d", got)
+	}
+	if got := c.QueryArray("id"); !reflect.DeepEqual(got, []string{"1", "2"}) {
+		t.Errorf("QueryArray(id) = %v, want [1 2]", got)
+	}
+	if got := c.QueryMap("ids"); !reflect.DeepEqual(got, map[string]string{"a": "x", "b": "y"}) {
+		t.Errorf("QueryMap(ids) = %v, want map[a:x b:y]", got)
+	}
+	if got, ok := c.GetQueryMap("id"); len(got) != 0 || ok {
+		t.Errorf("GetQueryMap(id) = (%v, %v), want empty", got, ok)
+	}
+
+	// the query is parsed once per request
+	c.Request.URL.RawQuery = "id=3"
+	if got := c.Query("id"); got != "1" {
+		t.Errorf("Query(id) after changing the URL = %q, want the cached 1", got)
+	}
+	c.reset()
+	if got := c.Query("id"); got != "3" {
+		t.Errorf("Query(id) after reset = %q, want 3", got)
+	}
+}
+
+func TestContextPostForm(t *testing.T) {
+	var multipartBody bytes.Buffer
+	mw := multipart.NewWriter(&multipartBody)
+	mw.WriteField("name", "klyn")
+	mw.WriteField("tags", "a")
+	mw.WriteField("tags", "b")
+	mw.WriteField("user[id]", "7")
+	mw.WriteField("user[role]", "admin")
+	mw.Close()
+
+	tests := []struct {
+		contentType, body string
+	}{
+		{MIMEPOSTForm, "name=klyn&tags=a&tags=b&user[id]=7&user[role]=admin"},
+		{mw.FormDataContentType(), multipartBody.String()},
+	}
+	for _, tt := range tests {
+		core := New()
+		core.POST("/", func(c *Context) {
+			if got := c.PostForm("name"); got != "klyn" {
+				t.Errorf("%s: PostForm(name) = %q, want klyn", tt.contentType, got)
+			}
+			if got := c.DefaultPostForm("missing", "d"); got != "d" {
+				t.Errorf("%s: DefaultPostForm(missing) = %q, want d", tt.contentType, got)
+			}
+			if got, ok := c.GetPostForm("missing"); got != "" || ok {
+				t.Errorf("%s: GetPostForm(missing) = (%q, %v), want (\"\", false)", tt.contentType, got, ok)
+			}
+			if got := c.PostFormArray("tags"); !reflect.DeepEqual(got, []string{"a", "b"}) {
+				t.Errorf("%s: PostFormArray(tags) = %v, want [a b]", tt.contentType, got)
+			}
+			if got := c.PostFormMap("user"); !reflect.DeepEqual(got, map[string]string{"id": "7", "role": "admin"}) {
+				t.Errorf("%s: PostFormMap(user) = %v, want map[id:7 role:admin]", tt.contentType, got)
+			}
+			// the URL query is not part of the body form
+			if got, ok := c.GetPostForm("q"); ok {
+				t.Errorf("%s: GetPostForm(q) = %q, want the query excluded", tt.contentType, got)
+			}
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "/?q=1", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		core.ServeHTTP(httptest.NewRecorder(), req)
+	}
+}
+
+func TestContextGetters(t *testing.T) {
+	c := &Context{}
+	c.Set("string", "s")
+	c.Set("int", 1)
+	c.Set("int64", int64(2))
+	c.Set("float64", 1.5)
+	c.Set("bool", true)
+
+	if c.GetString("string") != "s" || c.GetInt("int") != 1 || c.GetInt64("int64") != 2 ||
+		c.GetFloat64("float64") != 1.5 || !c.GetBool("bool") {
+		t.Error("getters returned the wrong values")
+	}
+	// missing keys give zero values
+	if c.GetString("missing") != "" || c.GetInt("missing") != 0 || c.GetBool("missing") {
+		t.Error("getters of missing keys returned non-zero values")
+	}
+	if _, ok := c.Get("missing"); ok {
+		t.Error("Get(missing) reported the key present")
+	}
+}
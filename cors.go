@@ -0,0 +1,168 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var defaultCORSMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost,
+	http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+// CORSConfig configures the CORS middleware. An origin is allowed if any of
+// AllowOrigins, AllowOriginPatterns or AllowOriginFunc allows it.
+type CORSConfig struct {
+	// AllowOrigins are the allowed origins, e.g. "https://example.com". "*"
+	// allows any origin, "https://*.example.com" any subdomain.
+	AllowOrigins []string
+	// AllowOriginPatterns are regular expressions matching allowed origins.
+	AllowOriginPatterns []string
+	// AllowOriginFunc reports whether the origin is allowed.
+	AllowOriginFunc func(origin string) bool
+
+	// AllowMethods answer preflight requests, GET, HEAD, POST, PUT, PATCH
+	// and DELETE by default.
+	AllowMethods []string
+	// AllowHeaders answer preflight requests, the requested headers are
+	// allowed if empty.
+	AllowHeaders []string
+	// ExposeHeaders are the response headers readable by the client.
+	ExposeHeaders []string
+	// AllowCredentials allows cookies and authorization headers. The origin
+	// is echoed instead of "*" then, as browsers require.
+	AllowCredentials bool
+	// MaxAge is how long the result of a preflight request may be cached.
+	MaxAge time.Duration
+}
+
+type corsOrigins struct {
+	any       bool
+	exact     map[string]bool
+	wildcards [][2]string // prefix and suffix around '*'
+	patterns  []*regexp.Regexp
+	fn        func(origin string) bool
+}
+
+func (o *corsOrigins) allowed(origin string) bool {
+	if o.any || o.exact[origin] {
+		return true
+	}
+	for _, w := range o.wildcards {
+		if len(origin) > len(w[0])+len(w[1]) && strings.HasPrefix(origin, w[0]) && strings.HasSuffix(origin, w[1]) &&
+			isSubdomain(origin[len(w[0]):len(origin)-len(w[1])]) {
+			return true
+		}
+	}
+	for _, re := range o.patterns {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return o.fn != nil && o.fn(origin)
+}
+
+// isSubdomain reports whether s only holds the letters, digits, '-' and '.'
+// of host names, so a wildcard can not match into the path or query.
+func isSubdomain(s string) bool {
+	for i := 0; i < len(s); i++ {
+		switch b := s[i]; {
+		case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9', b == '-', b == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// CORS returns a middleware adding the Cross-Origin Resource Sharing headers
+// to the responses of allowed origins. Preflight requests are answered with
+// 204, or 403 for origins not allowed. Use it as global middleware together
+// with Core.HandleOPTIONS, so preflight requests need no OPTIONS routes.
+func CORS(cfg CORSConfig) HandlerFunc {
+	origins := &corsOrigins{exact: make(map[string]bool), fn: cfg.AllowOriginFunc}
+	for _, origin := range cfg.AllowOrigins {
+		switch i := strings.IndexByte(origin, '*'); {
+		case origin == "*":
+			origins.any = true
+		case i >= 0:
+			origins.wildcards = append(origins.wildcards, [2]string{origin[:i], origin[i+1:]})
+		default:
+			origins.exact[origin] = true
+		}
+	}
+	for _, pattern := range cfg.AllowOriginPatterns {
+		origins.patterns = append(origins.patterns, regexp.MustCompile(pattern))
+	}
+
+	methods := cfg.AllowMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	allowMethods := strings.ToUpper(strings.Join(methods, ", "))
+	allowHeaders := strings.Join(cfg.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(cfg.ExposeHeaders, ", ")
+	maxAge := ""
+	if cfg.MaxAge > 0 {
+		maxAge = strconv.Itoa(int(cfg.MaxAge / time.Second))
+	}
+	anyOrigin := origins.any && !cfg.AllowCredentials
+
+	return func(c *Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		header := c.Writer.Header()
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if !anyOrigin {
+			addVary(header, "Origin")
+		}
+		if !origins.allowed(origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if anyOrigin {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if exposeHeaders != "" {
+				header.Set("Access-Control-Expose-Headers", exposeHeaders)
+			}
+			c.Next()
+			return
+		}
+
+		header.Set("Access-Control-Allow-Methods", allowMethods)
+		if allowHeaders != "" {
+			header.Set("Access-Control-Allow-Headers", allowHeaders)
+		} else if requested := c.GetHeader("Access-Control-Request-Headers"); requested != "" {
+			addVary(header, "Access-Control-Request-Headers")
+			header.Set("Access-Control-Allow-Headers", requested)
+		}
+		if maxAge != "" {
+			header.Set("Access-Control-Max-Age", maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
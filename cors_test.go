@@ -0,0 +1,150 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCORSOrigins(t *testing.T) {
+	core := New()
+	core.UseMiddleware(CORS(CORSConfig{
+		AllowOrigins:        []string{"https://example.com", "https://*.example.com"},
+		AllowOriginPatterns: []string{`^https://[a-z]+\.example\.org$`},
+		AllowOriginFunc:     func(origin string) bool { return origin == "https://func.test" },
+	}))
+	core.GET("/", func(c *Context) { c.String(http.StatusOK, "ok") })
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://example.com", true},
+		{"https://api.example.com", true},
+		{"https://a.b-c.example.com", true},
+		{"https://example.com.evil.com", false},
+		{"https://evil.com?.example.com", false},
+		{"https://evil.com/.example.com", false},
+		{"https://evil.com#.example.com", false},
+		{"https://user@evil.com:.example.com", false},
+		{"http://api.example.com", false},
+		{"https://www.example.org", true},
+		{"https://www.example.org.evil.com", false},
+		{"https://func.test", true},
+		{"https://other.test", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Origin", tt.origin)
+		w := httptest.NewRecorder()
+		core.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: status %d, want 200", tt.origin, w.Code)
+		}
+		got := w.Header().Get("Access-Control-Allow-Origin")
+		if allowed := got == tt.origin; allowed != tt.allowed {
+			t.Errorf("%s: Access-Control-Allow-Origin %q, want allowed %v", tt.origin, got, tt.allowed)
+		}
+		if vary := w.Header().Get("Vary"); vary != "Origin" {
+			t.Errorf("%s: Vary %q, want Origin", tt.origin, vary)
+		}
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	core := New()
+	core.UseMiddleware(CORS(CORSConfig{
+		AllowOrigins:     []string{"https://example.com"},
+		AllowMethods:     []string{"get", "post"},
+		ExposeHeaders:    []string{"X-Total"},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	}))
+	core.POST("/items", func(c *Context) { c.String(http.StatusCreated, "created") })
+
+	preflight := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/items", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+		req.Header.Set("Access-Control-Request-Headers", "Content-Type, X-Token")
+		w := httptest.NewRecorder()
+		core.ServeHTTP(w, req)
+		return w
+	}
+
+	w := preflight("https://example.com")
+	want := map[string]string{
+		"Access-Control-Allow-Origin":      "https://example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Methods":     "GET, POST",
+		"Access-Control-Allow-Headers":     "Content-Type, X-Token",
+		"Access-Control-Max-Age":           "3600",
+		"Access-Control-Expose-Headers":    "",
+	}
+	if w.Code != http.StatusNoContent {
+		t.Errorf("preflight: status %d, want 204", w.Code)
+	}
+	for key, value := range want {
+		if got := w.Header().Get(key); got != value {
+			t.Errorf("preflight: %s %q, want %q", key, got, value)
+		}
+	}
+	if vary := strings.Join(w.Header().Values("Vary"), ", "); !strings.Contains(vary, "Access-Control-Request-Headers") {
+		t.Errorf("preflight: Vary %q, want Access-Control-Request-Headers", vary)
+	}
+
+	if w = preflight("https://evil.com"); w.Code != http.StatusForbidden || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("preflight of a denied origin: status %d, Access-Control-Allow-Origin %q, want 403 without it",
+			w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/items", nil)
+	req.Header.Set("Origin", "https://example.com")
+	w = httptest.NewRecorder()
+	core.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated || w.Header().Get("Access-Control-Expose-Headers") != "X-Total" ||
+		w.Header().Get("Access-Control-Allow-Methods") != "" {
+		t.Errorf("simple request: status %d, headers %v", w.Code, w.Header())
+	}
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	for _, credentials := range []bool{false, true} {
+		core := New()
+		core.UseMiddleware(CORS(CORSConfig{AllowOrigins: []string{"*"}, AllowCredentials: credentials}))
+		core.GET("/", func(c *Context) { c.String(http.StatusOK, "ok") })
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Origin", "https://anywhere.test")
+		w := httptest.NewRecorder()
+		core.ServeHTTP(w, req)
+
+		wantOrigin, wantVary := "*", ""
+		if credentials {
+			wantOrigin, wantVary = "https://anywhere.test", "Origin"
+		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != wantOrigin {
+			t.Errorf("credentials %v: Access-Control-Allow-Origin %q, want %q", credentials, got, wantOrigin)
+		}
+		if got := w.Header().Get("Vary"); got != wantVary {
+			t.Errorf("credentials %v: Vary %q, want %q", credentials, got, wantVary)
+		}
+	}
+
+	// requests without Origin are no CORS requests
+	core := New()
+	core.UseMiddleware(CORS(CORSConfig{AllowOrigins: []string{"*"}}))
+	core.GET("/", func(c *Context) { c.String(http.StatusOK, "ok") })
+	w := httptest.NewRecorder()
+	core.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("no Origin: status %d, Access-Control-Allow-Origin %q", w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
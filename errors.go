@@ -0,0 +1,220 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const problemContent = "application/problem+json"
+
+// ErrorType classifies the errors of a Context, types can be combined as
+// flags for ErrorList.ByType.
+type ErrorType uint64
+
+const (
+	// ErrorTypePrivate errors are logged but not shown to clients.
+	ErrorTypePrivate ErrorType = 1 << 0
+	// ErrorTypePublic errors are shown to clients.
+	ErrorTypePublic ErrorType = 1 << 1
+	// ErrorTypeBind errors are failed bindings, see Context.Bind.
+	ErrorTypeBind ErrorType = 1 << 62
+	// ErrorTypeRender errors are failed renderings, see Context.Render.
+	ErrorTypeRender ErrorType = 1 << 63
+	// ErrorTypeAny matches any type.
+	ErrorTypeAny ErrorType = 1<<64 - 1
+)
+
+// Error is an error reported by a handler, see Context.Error.
+type Error struct {
+	Err  error
+	Type ErrorType
+	Meta interface{}
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// SetType sets the type of e.
+func (e *Error) SetType(t ErrorType) *Error {
+	e.Type = t
+	return e
+}
+
+// SetMeta attaches data to e, e.g. the id of the failed resource.
+func (e *Error) SetMeta(meta interface{}) *Error {
+	e.Meta = meta
+	return e
+}
+
+// IsType reports whether e has any of the type flags.
+func (e *Error) IsType(t ErrorType) bool {
+	return e.Type&t > 0
+}
+
+// ErrorList are the errors of a Context in the order they were reported.
+type ErrorList []*Error
+
+// ByType returns the errors of any of the type flags.
+func (list ErrorList) ByType(t ErrorType) ErrorList {
+	if t == ErrorTypeAny {
+		return list
+	}
+
+	var result ErrorList
+	for _, e := range list {
+		if e.IsType(t) {
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
+// Last returns the last error, or nil if there is none.
+func (list ErrorList) Last() *Error {
+	if len(list) == 0 {
+		return nil
+	}
+	return list[len(list)-1]
+}
+
+// Errors returns the messages of the errors.
+func (list ErrorList) Errors() []string {
+	msgs := make([]string, len(list))
+	for i, e := range list {
+		msgs[i] = e.Error()
+	}
+	return msgs
+}
+
+func (list ErrorList) String() string {
+	return strings.Join(list.Errors(), "; ")
+}
+
+// Error reports err for the error handling middleware, see ErrorHandler, and
+// returns it as *Error to set its type or meta. Errors are private unless
+// err is an *Error of another type already. An error wrapping an *Error is
+// reported as a new private *Error, so its message stays complete.
+func (c *Context) Error(err error) *Error {
+	assert1(err != nil, "err can not be nil")
+
+	e, ok := err.(*Error)
+	if !ok {
+		e = &Error{Err: err, Type: ErrorTypePrivate}
+	}
+	c.Errors = append(c.Errors, e)
+	return e
+}
+
+// Problem is the RFC 7807 problem details body written by ErrorHandler.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Errors lists the failed fields of a validation error.
+	Errors []ProblemField `json:"errors,omitempty"`
+}
+
+// ProblemField is a field failing validation.
+type ProblemField struct {
+	Field  string `json:"field"`
+	Rule   string `json:"rule"`
+	Detail string `json:"detail"`
+}
+
+// ErrorHandlerConfig configures ErrorHandlerWithConfig.
+type ErrorHandlerConfig struct {
+	// Status maps an error to the response status, 0 falls back to the
+	// default mapping.
+	Status func(err *Error) int
+	// Problem customizes the problem written for err, e.g. its Type URI.
+	Problem func(c *Context, err *Error, p *Problem)
+}
+
+// StatusCoder is implemented by errors carrying their response status.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// ErrorHandler returns a middleware answering requests whose handlers
+// reported errors by Context.Error, see ErrorHandlerWithConfig.
+func ErrorHandler() HandlerFunc {
+	return ErrorHandlerWithConfig(ErrorHandlerConfig{})
+}
+
+// ErrorHandlerWithConfig returns a middleware which writes the last error of
+// the Context as RFC 7807 problem after the chain finished, unless the
+// handlers wrote a response already. The status is taken from cfg.Status,
+// an error status set by the handlers, a StatusCoder error, or is 400 for
+// bind errors, 413 for ErrBodyTooLarge and 500 otherwise. Only the messages
+// of public and bind errors are shown to the client.
+func ErrorHandlerWithConfig(cfg ErrorHandlerConfig) HandlerFunc {
+	return func(c *Context) {
+		c.Next()
+
+		err := c.Errors.Last()
+		if err == nil || c.Writer.Written() {
+			return
+		}
+
+		status := 0
+		if cfg.Status != nil {
+			status = cfg.Status(err)
+		}
+		if status == 0 {
+			status = errorStatus(c, err)
+		}
+
+		p := Problem{
+			Type:     "about:blank",
+			Title:    http.StatusText(status),
+			Status:   status,
+			Instance: c.Request.URL.Path,
+		}
+		if err.IsType(ErrorTypePublic | ErrorTypeBind) {
+			p.Detail = err.Error()
+		}
+		var ve ValidationErrors
+		if errors.As(err, &ve) {
+			for _, fe := range ve {
+				rule := fe.Tag
+				if fe.Param != "" {
+					rule += "=" + fe.Param
+				}
+				p.Errors = append(p.Errors, ProblemField{Field: fe.Namespace, Rule: rule, Detail: fe.Error()})
+			}
+		}
+		if cfg.Problem != nil {
+			cfg.Problem(c, err, &p)
+		}
+
+		c.Writer.Header().Set("Content-Type", problemContent)
+		c.JSON(p.Status, p)
+	}
+}
+
+func errorStatus(c *Context, err *Error) int {
+	var sc StatusCoder
+	switch {
+	case c.Writer.Status() >= http.StatusBadRequest:
+		return c.Writer.Status()
+	case errors.As(err, &sc):
+		return sc.StatusCode()
+	case errors.Is(err, ErrBodyTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case err.IsType(ErrorTypeBind):
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,32 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestContextError(t *testing.T) {
+	c := &Context{}
+
+	public := &Error{Err: errors.New("not found"), Type: ErrorTypePublic}
+	if e := c.Error(public); e != public {
+		t.Error("an *Error is not reported as it is")
+	}
+
+	wrapped := fmt.Errorf("load user 5: %w", public)
+	e := c.Error(wrapped)
+	if e == public || e.Error() != "load user 5: not found" || !e.IsType(ErrorTypePrivate) {
+		t.Errorf("wrapped *Error reported as %q of type %v, want the full private error", e.Error(), e.Type)
+	}
+	if !errors.Is(e, public) {
+		t.Error("reported error does not wrap the *Error")
+	}
+	if len(c.Errors) != 2 {
+		t.Errorf("got %d errors, want 2", len(c.Errors))
+	}
+}
@@ -0,0 +1,384 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errUnknownType = errors.New("unknown type")
+
+	timeType       = reflect.TypeOf(time.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	fileHeaderType = reflect.TypeOf((*multipart.FileHeader)(nil))
+	unmarshalerTyp = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// formMapper sets struct fields from string values keyed by a struct tag.
+//
+// Supported tag options:
+//
+//	`form:"name"`              value key, defaults to the field name
+//	`form:"-"`                 skip the field
+//	`form:"name,default=val"`  value used when the key is absent
+//	`time_format:"2006-01-02"` layout of time.Time fields ("unix", "unixnano" allowed)
+type formMapper struct {
+	binding   string
+	tag       string
+	values    map[string][]string
+	files     map[string][]*multipart.FileHeader
+	canonical bool // keys are canonical MIME header keys
+
+	found   int                   // number of fields set from values or files
+	visited map[reflect.Type]bool // struct types being mapped, to stop on cycles
+}
+
+func mapForm(binding string, obj interface{}, values map[string][]string, tag string) error {
+	m := &formMapper{binding: binding, tag: tag, values: values}
+	return m.mapTo(obj)
+}
+
+func (m *formMapper) mapTo(obj interface{}) error {
+	rv := reflect.ValueOf(obj)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &BindError{Binding: m.binding, Err: ErrBindNilPointer}
+	}
+
+	rv = rv.Elem()
+	switch rv.Kind() {
+	case reflect.Struct:
+		return m.mapStruct(rv)
+	case reflect.Map:
+		return m.mapMap(rv)
+	default:
+		return &BindError{Binding: m.binding, Err: fmt.Errorf("can not bind into %s", rv.Type())}
+	}
+}
+
+// mapMap fills map[string]string and map[string][]string targets.
+func (m *formMapper) mapMap(rv reflect.Value) error {
+	t := rv.Type()
+	if t.Key().Kind() != reflect.String {
+		return &BindError{Binding: m.binding, Err: fmt.Errorf("can not bind into %s", t)}
+	}
+	if rv.IsNil() {
+		rv.Set(reflect.MakeMap(t))
+	}
+
+	switch {
+	case t.Elem().Kind() == reflect.String:
+		for k, vs := range m.values {
+			if len(vs) > 0 {
+				rv.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), reflect.ValueOf(vs[len(vs)-1]).Convert(t.Elem()))
+			}
+		}
+	case t.Elem().Kind() == reflect.Slice && t.Elem().Elem().Kind() == reflect.String:
+		for k, vs := range m.values {
+			rv.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), reflect.ValueOf(vs).Convert(t.Elem()))
+		}
+	default:
+		return &BindError{Binding: m.binding, Err: fmt.Errorf("can not bind into %s", t)}
+	}
+
+	return nil
+}
+
+func (m *formMapper) mapStruct(rv reflect.Value) error {
+	t := rv.Type()
+	if m.visited == nil {
+		m.visited = make(map[reflect.Type]bool)
+	}
+	m.visited[t] = true
+	defer delete(m.visited, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous { // unexported
+			continue
+		}
+
+		name, opts := parseTag(sf.Tag.Get(m.tag))
+		if name == "-" {
+			continue
+		}
+
+		fv := rv.Field(i)
+		if name == "" && isNestedStruct(sf.Type) {
+			if err := m.mapNested(fv); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+
+		if err := m.mapField(fv, sf, name, opts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mapNested maps an untagged struct field. A nil pointer is only allocated
+// if a value is set below it, a pointer to a struct type being mapped, e.g.
+// Next *Node of Node, is skipped.
+func (m *formMapper) mapNested(fv reflect.Value) error {
+	if fv.Kind() != reflect.Ptr {
+		return m.mapStruct(fv)
+	}
+
+	t := fv.Type().Elem()
+	if m.visited[t] {
+		return nil
+	}
+	if !fv.IsNil() {
+		return m.mapStruct(fv.Elem())
+	}
+	if !fv.CanSet() {
+		return nil
+	}
+
+	nv := reflect.New(t)
+	found := m.found
+	if err := m.mapStruct(nv.Elem()); err != nil {
+		return err
+	}
+	if m.found > found {
+		fv.Set(nv)
+	}
+	return nil
+}
+
+func (m *formMapper) mapField(fv reflect.Value, sf reflect.StructField, name string, opts tagOptions) error {
+	if !fv.CanSet() {
+		return nil
+	}
+	if m.files != nil {
+		if ok, err := m.mapFile(fv, sf, name); ok {
+			return err
+		}
+	}
+
+	values, ok := m.lookup(name)
+	if ok {
+		m.found++
+	} else {
+		def, hasDefault := opts.get("default")
+		if !hasDefault {
+			return nil
+		}
+		values = []string{def}
+	}
+
+	if err := setField(fv, sf, values); err != nil {
+		return &BindError{Binding: m.binding, Field: sf.Name, Err: err}
+	}
+
+	return nil
+}
+
+func (m *formMapper) mapFile(fv reflect.Value, sf reflect.StructField, name string) (bool, error) {
+	switch {
+	case sf.Type == fileHeaderType:
+		if files := m.files[name]; len(files) > 0 {
+			fv.Set(reflect.ValueOf(files[0]))
+			m.found++
+		}
+		return true, nil
+	case sf.Type.Kind() == reflect.Slice && sf.Type.Elem() == fileHeaderType:
+		if files := m.files[name]; len(files) > 0 {
+			fv.Set(reflect.ValueOf(files))
+			m.found++
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (m *formMapper) lookup(name string) ([]string, bool) {
+	if m.canonical {
+		name = textproto.CanonicalMIMEHeaderKey(name)
+	}
+	values, ok := m.values[name]
+	return values, ok
+}
+
+func isNestedStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && t != timeType && !reflect.PtrTo(t).Implements(unmarshalerTyp)
+}
+
+func setField(fv reflect.Value, sf reflect.StructField, values []string) error {
+	switch fv.Kind() {
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() == reflect.Uint8 && !fv.Type().Implements(unmarshalerTyp) {
+			fv.SetBytes([]byte(values[0]))
+			return nil
+		}
+		slice := reflect.MakeSlice(fv.Type(), len(values), len(values))
+		for i, s := range values {
+			if err := setValue(slice.Index(i), sf, s); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	case reflect.Array:
+		if len(values) != fv.Len() {
+			return fmt.Errorf("%q is not valid value for %s", values, fv.Type())
+		}
+		for i, s := range values {
+			if err := setValue(fv.Index(i), sf, s); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return setValue(fv, sf, values[0])
+	}
+}
+
+func setValue(v reflect.Value, sf reflect.StructField, s string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setValue(v.Elem(), sf, s)
+	}
+
+	switch v.Type() {
+	case timeType:
+		return setTime(v, sf, s)
+	case durationType:
+		if s == "" {
+			s = "0"
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	if v.CanAddr() && v.Addr().Type().Implements(unmarshalerTyp) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		if s == "" {
+			s = "false"
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if s == "" {
+			s = "0"
+		}
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if s == "" {
+			s = "0"
+		}
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		if s == "" {
+			s = "0"
+		}
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return errUnknownType
+	}
+
+	return nil
+}
+
+func setTime(v reflect.Value, sf reflect.StructField, s string) error {
+	if s == "" {
+		v.Set(reflect.ValueOf(time.Time{}))
+		return nil
+	}
+
+	layout := sf.Tag.Get("time_format")
+	switch layout {
+	case "":
+		layout = time.RFC3339
+	case "unix", "unixnano":
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		t := time.Unix(n, 0)
+		if layout == "unixnano" {
+			t = time.Unix(0, n)
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(t))
+	return nil
+}
+
+type tagOptions string
+
+func parseTag(tag string) (string, tagOptions) {
+	if i := strings.Index(tag, ","); i >= 0 {
+		return tag[:i], tagOptions(tag[i+1:])
+	}
+	return tag, ""
+}
+
+// get returns the value of the `key=value` option.
+func (o tagOptions) get(key string) (string, bool) {
+	s := string(o)
+	for s != "" {
+		var opt string
+		opt, s = s, ""
+		if i := strings.Index(opt, ","); i >= 0 {
+			opt, s = opt[:i], opt[i+1:]
+		}
+		if k := strings.Index(opt, "="); k >= 0 && opt[:k] == key {
+			return opt[k+1:], true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,51 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import "testing"
+
+type formNode struct {
+	Name string `form:"name"`
+	Next *formNode
+}
+
+type formOuter struct {
+	ID    int `form:"id"`
+	Inner *struct {
+		Page int `form:"page"`
+	}
+}
+
+func TestMapFormSelfReferential(t *testing.T) {
+	var n formNode
+	if err := mapForm("query", &n, map[string][]string{"name": {"a"}}, "form"); err != nil {
+		t.Fatal(err)
+	}
+	if n.Name != "a" || n.Next != nil {
+		t.Errorf("got %+v, want Name a and nil Next", n)
+	}
+}
+
+func TestMapFormNestedPointer(t *testing.T) {
+	tests := []struct {
+		values    map[string][]string
+		wantInner bool
+	}{
+		{map[string][]string{"id": {"1"}}, false},
+		{map[string][]string{"id": {"1"}, "page": {"2"}}, true},
+	}
+	for _, tt := range tests {
+		var o formOuter
+		if err := mapForm("query", &o, tt.values, "form"); err != nil {
+			t.Fatal(err)
+		}
+		if (o.Inner != nil) != tt.wantInner {
+			t.Errorf("%v: Inner allocated %v, want %v", tt.values, o.Inner != nil, tt.wantInner)
+		}
+		if tt.wantInner && o.Inner.Page != 2 {
+			t.Errorf("%v: Page %d, want 2", tt.values, o.Inner.Page)
+		}
+	}
+}
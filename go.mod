@@ -1,11 +1,16 @@
 module github.com/yusank/klyn
 
-go 1.17
+go 1.18
 
-require github.com/yusank/klyn-log v0.0.0-20200309073155-ce11556f390e
+require (
+	github.com/json-iterator/go v1.1.12
+	github.com/vmihailenco/msgpack/v5 v5.3.5
+	github.com/yusank/klyn-log v0.0.0-20200309073155-ce11556f390e
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
-	github.com/json-iterator/go v1.1.12 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180228061459-e0a39a4cb421 // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
 )
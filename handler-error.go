@@ -0,0 +1,88 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import "reflect"
+
+// ErrHandlerFunc is a handler returning its error instead of responding to it.
+type ErrHandlerFunc func(*Context) error
+
+// handlerDesc describes the handler wrapped by WrapErr or Typed, so
+// HandlerName and RouteInfo report the handler itself.
+type handlerDesc struct {
+	name              string
+	request, response reflect.Type
+}
+
+// describedPC is the code of all handlers returned by describedHandler.
+var describedPC = reflect.ValueOf(describedHandler(nil, nil)).Pointer()
+
+// describedHandler returns serve as HandlerFunc which reports desc instead
+// if it is called by describe.
+//
+//go:noinline
+func describedHandler(desc *handlerDesc, serve HandlerFunc) HandlerFunc {
+	return func(c *Context) {
+		if c.describe != nil {
+			*c.describe = *desc
+			return
+		}
+		serve(c)
+	}
+}
+
+// describe returns the description of a handler returned by WrapErr or
+// Typed, nil for other handlers, which are not called.
+func describe(h HandlerFunc) *handlerDesc {
+	if h == nil || reflect.ValueOf(h).Pointer() != describedPC {
+		return nil
+	}
+	desc := new(handlerDesc)
+	h(&Context{describe: desc})
+	return desc
+}
+
+// WrapErr adapts h to a HandlerFunc, so it can be mixed with other handlers
+// and middleware. A returned error is reported by Context.Error and aborts
+// the chain, the status is set as ErrorHandler would unless the response was
+// written already.
+func WrapErr(h ErrHandlerFunc) HandlerFunc {
+	assert1(h != nil, "handler can not be nil")
+
+	return describedHandler(&handlerDesc{name: nameOfFunction(h)}, func(c *Context) {
+		if err := h(c); err != nil {
+			abortWithError(c, c.Error(err))
+		}
+	})
+}
+
+// abortWithError aborts the chain with the status of the reported error.
+func abortWithError(c *Context, e *Error) {
+	c.Abort()
+	if !c.Writer.Written() {
+		c.Status(errorStatus(c, e))
+	}
+}
+
+// HandleErr registers handlers returning errors, see WrapErr.
+func (rg *RouterGroup) HandleErr(method, relativePath string, handlers ...ErrHandlerFunc) KRoutes {
+	chain := make(HandlersChain, len(handlers))
+	for i, h := range handlers {
+		chain[i] = WrapErr(h)
+	}
+	return rg.Handle(method, relativePath, chain...)
+}
+
+// handlerName returns the name of the function of h, or of the handler
+// wrapped by WrapErr or Typed.
+func handlerName(h HandlerFunc) string {
+	if desc := describe(h); desc != nil {
+		return desc.name
+	}
+	if h == nil {
+		return ""
+	}
+	return nameOfFunction(h)
+}
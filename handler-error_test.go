@@ -0,0 +1,66 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type describeReq struct {
+	ID int `uri:"id"`
+}
+
+func describeErrHandler(c *Context) error {
+	return errors.New("failed")
+}
+
+func describeTypedHandler(c *Context, req describeReq) (K, error) {
+	return K{"id": req.ID}, nil
+}
+
+func TestRouteInfoOfWrappedHandlers(t *testing.T) {
+	core := New()
+	called := false
+	core.GET("/plain", func(c *Context) { called = true })
+	core.GET("/err", WrapErr(describeErrHandler))
+	core.GET("/typed/:id", WrapErr(describeErrHandler), Typed(describeTypedHandler))
+
+	if called {
+		t.Fatal("a plain handler was called on registration")
+	}
+
+	infos := make(map[string]RouteInfo)
+	for _, info := range core.Routes() {
+		infos[info.Path] = info
+	}
+	if h := infos["/err"].Handler; !strings.HasSuffix(h, ".describeErrHandler") {
+		t.Errorf("handler of /err is %q, want describeErrHandler", h)
+	}
+
+	typed := infos["/typed/:id"]
+	if !strings.HasSuffix(typed.Handler, ".describeTypedHandler") {
+		t.Errorf("handler of /typed/:id is %q, want describeTypedHandler", typed.Handler)
+	}
+	if len(typed.Middlewares) != 1 || !strings.HasSuffix(typed.Middlewares[0], ".describeErrHandler") {
+		t.Errorf("middlewares of /typed/:id are %v, want describeErrHandler", typed.Middlewares)
+	}
+	if typed.Request != reflect.TypeOf(describeReq{}) || typed.Response != reflect.TypeOf(K{}) {
+		t.Errorf("types of /typed/:id are %v, %v", typed.Request, typed.Response)
+	}
+	if infos["/plain"].Request != nil {
+		t.Errorf("plain handler has request type %v", infos["/plain"].Request)
+	}
+
+	w := httptest.NewRecorder()
+	core.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/err", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("GET /err: got %d, want 500", w.Code)
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// hostRouter holds the routes of the hosts matching its pattern, see Core.Host.
+type hostRouter struct {
+	pattern string
+	tokens  []pathToken // of a pattern with params
+	suffix  string      // of a wildcard pattern, e.g. ".example.com" of "*.example.com"
+	trees   methodTrees
+}
+
+// Host returns a router for the requests to the hosts matching pattern,
+// which has routes of its own, e.g.
+//
+//	api := core.Host("api.example.com")
+//	api.GET("/users/:id", show)
+//
+//	tenant := core.Host(":tenant.example.com")
+//	tenant.GET("/", home) // c.Params.ByName("tenant")
+//
+// Params of a pattern match (a part of) a single label and may have
+// constraints as in route paths, a pattern starting with "*." matches any
+// subdomain. Exact hosts win over patterns with params, which are tried in
+// order and win over wildcards, the longest first. Requests to other hosts
+// are routed by the routes of Core. Hosts are matched case-insensitively
+// without port, see ForwardByHost for hosts behind a proxy.
+func (core *Core) Host(pattern string, handlers ...HandlerFunc) *RouterGroup {
+	return &RouterGroup{
+		Handlers: core.combineHandlers(handlers),
+		basePath: core.basePath,
+		core:     core,
+		host:     core.hostRouter(pattern),
+	}
+}
+
+// hostRouter returns the host router of pattern, adding it if needed.
+func (core *Core) hostRouter(pattern string) *hostRouter {
+	assert1(pattern != "", "host pattern can not be empty")
+	assert1(!strings.Contains(pattern, "/"), "host pattern '"+pattern+"' can not contain '/'")
+
+	h := &hostRouter{trees: make(methodTrees, 0, 9)}
+	if strings.HasPrefix(pattern, "*.") {
+		h.suffix = strings.ToLower(pattern[1:])
+		h.pattern = "*" + h.suffix
+	} else {
+		tokens, err := parsePath(pattern)
+		if err != nil {
+			panic(err)
+		}
+
+		var sb strings.Builder
+		for i := range tokens {
+			tok := &tokens[i]
+			switch {
+			case tok.kind == static:
+				tok.text = strings.ToLower(tok.text)
+			case tok.kind != param || tok.optional:
+				panic(fmt.Sprintf("klyn: host pattern '%s' can only have params", pattern))
+			default:
+				h.tokens = tokens
+			}
+			sb.WriteString(tok.text)
+		}
+		h.pattern = sb.String()
+	}
+
+	for _, existing := range core.hosts {
+		if existing.pattern == h.pattern {
+			return existing
+		}
+	}
+	core.hosts = append(core.hosts, h)
+	if h.tokens == nil && h.suffix == "" {
+		if core.exactHosts == nil {
+			core.exactHosts = make(map[string]*hostRouter)
+		}
+		core.exactHosts[h.pattern] = h
+	}
+	return h
+}
+
+// matchHost returns the host router of host, appending the values of the
+// host params to p, or nil if the routes of Core serve the host.
+func (core *Core) matchHost(host string, p Params) (*hostRouter, Params) {
+	host = strings.ToLower(host)
+	if h, ok := core.exactHosts[host]; ok {
+		return h, p
+	}
+
+	var wildcard *hostRouter
+	for _, h := range core.hosts {
+		switch {
+		case h.tokens != nil:
+			if ps, ok := matchHostTokens(h.tokens, host, p); ok {
+				return h, ps
+			}
+		case h.suffix != "":
+			if len(host) > len(h.suffix) && strings.HasSuffix(host, h.suffix) &&
+				(wildcard == nil || len(h.suffix) > len(wildcard.suffix)) {
+				wildcard = h
+			}
+		}
+	}
+	return wildcard, p
+}
+
+// hostOf returns the host router of the request, nil for the routes of Core.
+func (core *Core) hostOf(c *Context) *hostRouter {
+	if len(core.hosts) == 0 {
+		return nil
+	}
+	h, _ := core.matchHost(c.Host(), nil)
+	return h
+}
+
+// matchHostTokens matches host against the tokens of a host pattern. A param
+// value ends within its label before the static text following the param,
+// shortest value first.
+func matchHostTokens(tokens []pathToken, host string, p Params) (Params, bool) {
+	if len(tokens) == 0 {
+		return p, host == ""
+	}
+
+	tok := tokens[0]
+	if tok.kind == static {
+		if !strings.HasPrefix(host, tok.text) {
+			return p, false
+		}
+		return matchHostTokens(tokens[1:], host[len(tok.text):], p)
+	}
+
+	end := strings.IndexByte(host, '.')
+	if end < 0 {
+		end = len(host)
+	}
+	for i := 1; i <= end; i++ {
+		value := host[:i]
+		if tok.constraint != nil && !tok.constraint(value) {
+			continue
+		}
+		if ps, ok := matchHostTokens(tokens[1:], host[i:], append(p, Param{Key: tok.name, Value: value})); ok {
+			return ps, true
+		}
+	}
+	return p, false
+}
+
+// Host returns the host name of the request without port. It is the first
+// value of the X-Forwarded-Host header if ForwardByHost is set.
+func (c *Context) Host() string {
+	host := c.Request.Host
+	if c.core.ForwardByHost {
+		if fwd := strings.TrimSpace(strings.Split(c.GetHeader("X-Forwarded-Host"), ",")[0]); fwd != "" {
+			host = fwd
+		}
+	}
+
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		return name
+	}
+	// an IPv6 address without port, e.g. "[::1]"
+	return strings.Trim(host, "[]")
+}
+
+// hostParamNames returns the names of the params of a host pattern.
+func hostParamNames(pattern string) []string {
+	if pattern == "" || strings.HasPrefix(pattern, "*.") {
+		return nil
+	}
+	return paramNames(pattern)
+}
+
+// hostPath returns the pattern of the URL of path on the host of pattern,
+// or path for the routes of Core and wildcard hosts.
+func hostPath(pattern, path string) string {
+	if pattern == "" || strings.HasPrefix(pattern, "*.") {
+		return path
+	}
+	return "//" + pattern + path
+}
@@ -0,0 +1,111 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestContextHost(t *testing.T) {
+	tests := map[string]string{
+		"example.com":      "example.com",
+		"example.com:8080": "example.com",
+		"[::1]:8080":       "::1",
+		"[::1]":            "::1",
+		"127.0.0.1":        "127.0.0.1",
+	}
+	for host, want := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Host = host
+		c := &Context{core: New(), Request: req}
+		if got := c.Host(); got != want {
+			t.Errorf("Host of %q = %q, want %q", host, got, want)
+		}
+	}
+}
+
+func TestHostRouteInfoAndURL(t *testing.T) {
+	core := New()
+	core.GET("/users/:id", func(c *Context) {}).Name("user.show")
+	tenant := core.Host(":tenant.example.com")
+	tenant.GET("/users/:id", func(c *Context) {
+		u, err := c.URL("user.show", "tenant", c.Params.ByName("tenant"), "id", "7")
+		if err != nil {
+			c.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		c.String(http.StatusOK, u)
+	}).Name("user.show")
+	core.Host("*.example.org").GET("/users/:id", func(c *Context) {}).Name("user.show")
+
+	for _, info := range core.Routes() {
+		want := []string{"id"}
+		if info.Host == ":tenant.example.com" {
+			want = []string{"tenant", "id"}
+		}
+		if !reflect.DeepEqual(info.Params, want) {
+			t.Errorf("params of %s%s are %v, want %v", info.Host, info.Path, info.Params, want)
+		}
+	}
+
+	urls := []struct {
+		rg   *RouterGroup
+		want string
+	}{
+		{&core.RouterGroup, "/users/42"},
+		{tenant, "//acme.example.com/users/42"},
+		{core.Host("*.example.org"), "/users/42"},
+	}
+	for _, tt := range urls {
+		pairs := []string{"id", "42"}
+		if tt.rg == tenant {
+			pairs = append(pairs, "tenant", "acme")
+		}
+		if u, err := tt.rg.URL("user.show", pairs...); err != nil || u != tt.want {
+			t.Errorf("URL = %q, %v, want %q", u, err, tt.want)
+		}
+	}
+	if _, err := tenant.URL("user.show", "id", "42"); err == nil {
+		t.Error("URL without the host param succeeded")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Host = "acme.example.com"
+	w := httptest.NewRecorder()
+	core.ServeHTTP(w, req)
+	if body := w.Body.String(); body != "//acme.example.com/users/7" {
+		t.Errorf("Context.URL on the tenant host = %q", body)
+	}
+}
+
+func TestHostNoRoute(t *testing.T) {
+	core := New()
+	core.NoRoute(func(c *Context) { c.String(http.StatusNotFound, "core") })
+	core.Host("api.example.com").GET("/users", func(c *Context) {})
+	admin := core.Host("admin.example.com")
+	admin.GET("/users", func(c *Context) {})
+	admin.Group("/reports").NoRoute(func(c *Context) { c.String(http.StatusNotFound, "reports") })
+
+	tests := []struct {
+		host, path, body string
+	}{
+		{"example.com", "/missing", "core"},
+		{"api.example.com", "/missing", "core"},
+		{"admin.example.com", "/missing", "core"},
+		{"admin.example.com", "/reports/missing", "reports"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.Host = tt.host
+		w := httptest.NewRecorder()
+		core.ServeHTTP(w, req)
+		if w.Code != http.StatusNotFound || w.Body.String() != tt.body {
+			t.Errorf("%s%s: got %d %q, want 404 %q", tt.host, tt.path, w.Code, w.Body.String(), tt.body)
+		}
+	}
+}
@@ -5,18 +5,24 @@
 package klyn
 
 import (
+	"context"
+	"html/template"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"reflect"
 	"runtime"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
 const (
 	// Version - version of project
 	Version                = "v0.0.1"
-	defaultMultipartMemory = 32 << 10 // 16M
+	defaultMultipartMemory = 32 << 20 // 32 MB, as net/http
 )
 
 type HandlerFunc func(*Context)
@@ -31,10 +37,41 @@ func (c HandlersChain) Last() HandlerFunc {
 	return nil
 }
 
+// RouteInfo describes a registered route.
 type RouteInfo struct {
-	Method  string
-	Path    string
-	Handler string
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	Handler string `json:"handler"`
+
+	// Host is the pattern of the host router of the route, see Core.Host.
+	Host string `json:"host,omitempty"`
+	// Name is set by KRoutes.Name, empty for unnamed routes.
+	Name string `json:"name,omitempty"`
+	// Group is the base path of the group the route was registered on.
+	Group string `json:"group"`
+	// Middlewares are the names of the handlers run before Handler.
+	Middlewares []string `json:"middlewares,omitempty"`
+	// Params are the names of the host and path params, in order.
+	Params []string `json:"params,omitempty"`
+	// Metadata is set by KRoutes.Meta.
+	Metadata K `json:"metadata,omitempty"`
+	// Request and Response are the types of a Typed handler, nil otherwise.
+	Request  reflect.Type `json:"-"`
+	Response reflect.Type `json:"-"`
+}
+
+// clone copies info, the copy shares no slice or map with it.
+func (info *RouteInfo) clone() RouteInfo {
+	c := *info
+	c.Middlewares = append([]string(nil), info.Middlewares...)
+	c.Params = append([]string(nil), info.Params...)
+	if info.Metadata != nil {
+		c.Metadata = make(K, len(info.Metadata))
+		for k, v := range info.Metadata {
+			c.Metadata[k] = v
+		}
+	}
+	return c
 }
 
 type RoutesInfo []RouteInfo
@@ -43,14 +80,76 @@ type RoutesInfo []RouteInfo
 type Core struct {
 	RouterGroup
 
+	// RedirectTrailingSlash redirects to the path with (or without) a trailing
+	// slash when only that variant has a route, e.g. /foo/ to /foo.
+	RedirectTrailingSlash bool
+
+	// RedirectFixedPath redirects to the cleaned, case-insensitive match of the
+	// path if there is no route for it, e.g. /FOO and /..//Foo to /foo.
+	RedirectFixedPath bool
+
 	UseRawPath             bool
 	UnescapePathValues     bool
 	HandleMethodNotAllowed bool
 
+	// HandleOPTIONS answers OPTIONS requests of paths without an OPTIONS
+	// route with 204 and the Allow header. The global middleware runs
+	// first, so a CORS middleware can answer preflight requests.
+	HandleOPTIONS bool
+
 	ForwardByClientIP bool
 
+	// ForwardByHost routes by the X-Forwarded-Host header of a trusted proxy
+	// instead of the Host header, see Core.Host and Context.Host.
+	ForwardByHost bool
+
+	// Validator validates structs after binding, set to nil to disable validation.
+	// If it has a ValidateType(reflect.Type) error method, as DefaultValidator,
+	// the request types of Typed handlers are checked when registered.
+	Validator StructValidator
+
+	// JSONCodec renders and binds json, StdJSONCodec by default.
+	JSONCodec JSONCodec
+
+	// SecureJSONPrefix prefixes json arrays written by Context.SecureJSON.
+	SecureJSONPrefix string
+
+	// FuncMap is used by LoadHTMLGlob and LoadHTMLFiles.
+	FuncMap template.FuncMap
+
+	// MaxMultipartMemory is how much of a multipart form is held in memory,
+	// larger files are spilled to temporary files on disk. 32 MB by default.
+	MaxMultipartMemory int64
+
+	// RequestBody limits the request bodies of all routes, see LimitRequestBody.
+	// It is applied by the first handler of the route, rejected requests are
+	// answered after the global middleware, like requests without route.
+	RequestBody RequestBodyConfig
+
+	// ShutdownTimeout is how long Run waits for in-flight requests on shutdown,
+	// zero waits until they are all done.
+	ShutdownTimeout time.Duration
+
 	trees methodTrees
 	pool  sync.Pool
+
+	hosts      []*hostRouter // in order of registration, see Core.Host
+	exactHosts map[string]*hostRouter
+
+	routeInfos  map[string]*RouteInfo // keyed by "METHOD host path"
+	namedRoutes map[string]*RouteInfo // keyed by "host name"
+
+	noRoutes []*noRoute // not-found handlers of groups, see RouterGroup.NoRoute
+	noMethod HandlersChain
+
+	renders      map[string]RenderFactory
+	htmlTemplate *template.Template
+
+	serverMu   sync.Mutex
+	server     *http.Server // configuration, see Core.Server
+	running    *http.Server // serving since the last start
+	onStart    []func()
+	onShutdown []func(ctx context.Context)
 }
 
 var _ KRouter = &Core{}
@@ -67,7 +166,14 @@ func New() *Core {
 			root:     true,
 		},
 
+		RedirectTrailingSlash:  true,
 		HandleMethodNotAllowed: true,
+		HandleOPTIONS:          true,
+		Validator:              NewValidator(),
+		JSONCodec:              StdJSONCodec{},
+		SecureJSONPrefix:       defaultSecureJSONPrefix,
+		MaxMultipartMemory:     defaultMultipartMemory,
+		ShutdownTimeout:        defaultShutdownTimeout,
 		trees:                  make(methodTrees, 0, 9),
 	}
 	core.pool.New = func() interface{} {
@@ -81,7 +187,7 @@ func New() *Core {
 
 func Default() *Core {
 	core := New()
-	core.UseMiddleware(Logger())
+	core.UseMiddleware(Logger(), Recovery())
 	return core
 }
 
@@ -94,58 +200,153 @@ func (core *Core) UseMiddleware(middleware ...HandlerFunc) KRoutes {
 	return core
 }
 
-func (core *Core) addRouter(method, path string, handlers HandlersChain) {
+// NoMethod sets the handlers answering requests whose path only has routes
+// of other methods, see HandleMethodNotAllowed. They run after the global
+// middleware, the response is 405 unless they set another status.
+func (core *Core) NoMethod(handlers ...HandlerFunc) {
+	core.noMethod = handlers
+}
+
+// noRoute are the not-found handlers of the group.
+type noRoute struct {
+	group    *RouterGroup
+	handlers HandlersChain
+}
+
+// findNoRoute returns the not-found handlers of the group of host with the
+// longest path matching path, those of the groups of Core if host has none,
+// or nil if there are none.
+func (core *Core) findNoRoute(host *hostRouter, path string) *noRoute {
+	if nr := core.findHostNoRoute(host, path); nr != nil || host == nil {
+		return nr
+	}
+	return core.findHostNoRoute(nil, path)
+}
+
+func (core *Core) findHostNoRoute(host *hostRouter, path string) *noRoute {
+	var found *noRoute
+	for _, nr := range core.noRoutes {
+		prefix := nr.group.basePath
+		if nr.group.host != host {
+			continue
+		}
+		if path != prefix && !strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/") {
+			continue
+		}
+		if found == nil || len(prefix) > len(found.group.basePath) {
+			found = nr
+		}
+	}
+	return found
+}
+
+func (core *Core) addRouter(host *hostRouter, method, path, group string, handlers HandlersChain) *RouteInfo {
 	assert1(path[0] == '/', "path must begin with '/'")
 	assert1(method != "", "HTTP method can not be empty")
 	assert1(len(handlers) > 0, "there must be at least one handler")
 
-	printRouter(method, path, handlers)
-	root := core.trees.get(method)
+	trees, hostPattern := &core.trees, ""
+	if host != nil {
+		trees, hostPattern = &host.trees, host.pattern
+	}
+
+	printRouter(method, hostPattern+path, handlers)
+	root := trees.get(method)
 	if root == nil {
 		root = new(node)
-		core.trees = append(core.trees, methodTree{method: method, root: root})
+		*trees = append(*trees, methodTree{method: method, root: root})
+	}
+
+	if err := root.addRoute(path, handlers); err != nil {
+		if conflict, ok := err.(*RouteConflictError); ok {
+			conflict.Method = method
+		}
+		panic(err)
 	}
 
-	root.addRoute(path, handlers)
+	info := newRouteInfo(method, hostPattern, path, group, handlers)
+	if tv, ok := core.Validator.(interface{ ValidateType(reflect.Type) error }); ok && info.Request != nil {
+		// report bad `validate` tags of Typed handlers now, not on the first request
+		if err := tv.ValidateType(info.Request); err != nil {
+			panic(err)
+		}
+	}
+	if core.routeInfos == nil {
+		core.routeInfos = make(map[string]*RouteInfo)
+	}
+	core.routeInfos[method+" "+hostPattern+" "+path] = info
+	return info
 }
 
 func printRouter(method, path string, handlers HandlersChain) {
-	handlerName := nameOfFunction(handlers.Last())
-	log.Printf("%-7s  %-20s --> %s (handlers:%d) \n", method, path, handlerName, len(handlers))
+	log.Printf("%-7s  %-20s --> %s (handlers:%d) \n", method, path, handlerName(handlers.Last()), len(handlers))
 }
 
+func newRouteInfo(method, host, path, group string, handlers HandlersChain) *RouteInfo {
+	info := &RouteInfo{
+		Method:  method,
+		Host:    host,
+		Path:    path,
+		Handler: handlerName(handlers.Last()),
+		Group:   group,
+		Params:  append(hostParamNames(host), paramNames(path)...),
+	}
+	if desc := describe(handlers.Last()); desc != nil {
+		info.Request, info.Response = desc.request, desc.response
+	}
+	for _, h := range handlers[:len(handlers)-1] {
+		info.Middlewares = append(info.Middlewares, handlerName(h))
+	}
+	return info
+}
+
+// Routes returns all registered routes, those of the host routers last.
 func (core *Core) Routes() (routes RoutesInfo) {
 	for _, tree := range core.trees {
-		iterate(tree.method, "", routes, tree.root)
+		routes = core.iterate(tree.method, "", routes, tree.root, make(map[string]bool))
+	}
+	for _, host := range core.hosts {
+		for _, tree := range host.trees {
+			routes = core.iterate(tree.method, host.pattern, routes, tree.root, make(map[string]bool))
+		}
 	}
 
 	return routes
 }
 
-func iterate(method, path string, routes RoutesInfo, root *node) RoutesInfo {
-	path += root.path
-	if len(root.handlers) > 0 {
-		routes = append(routes, RouteInfo{
-			Method:  method,
-			Path:    path,
-			Handler: nameOfFunction(root.handlers.Last()),
-		})
+// iterate appends the routes below root, seen skips the second node of a
+// route with an optional param.
+func (core *Core) iterate(method, host string, routes RoutesInfo, root *node, seen map[string]bool) RoutesInfo {
+	if path := root.fullPath; len(root.handlers) > 0 && !seen[path] {
+		seen[path] = true
+		if info, ok := core.routeInfos[method+" "+host+" "+path]; ok {
+			routes = append(routes, info.clone())
+		} else {
+			routes = append(routes, *newRouteInfo(method, host, path, "", root.handlers))
+		}
 	}
 	for _, child := range root.children {
-		routes = iterate(path, method, routes, child)
+		routes = core.iterate(method, host, routes, child, seen)
+	}
+	for _, child := range root.params {
+		routes = core.iterate(method, host, routes, child, seen)
+	}
+	if root.catchAll != nil {
+		routes = core.iterate(method, host, routes, root.catchAll, seen)
 	}
 	return routes
 }
 
-func nameOfFunction(handler interface{}) string {
-	return runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
+// RoutesHandler serves the route table of the core as json, mount it on a
+// debug path, e.g. core.GET("/debug/routes", klyn.RoutesHandler()).
+func RoutesHandler() HandlerFunc {
+	return func(c *Context) {
+		c.JSON(http.StatusOK, c.core.Routes())
+	}
 }
 
-func (core *Core) Service(addr ...string) (err error) {
-	address := resolveAddress(addr)
-	log.Println("start service on:", address)
-	err = http.ListenAndServe(address, core)
-	return
+func nameOfFunction(handler interface{}) string {
+	return runtime.FuncForPC(reflect.ValueOf(handler).Pointer()).Name()
 }
 
 func resolveAddress(addr []string) string {
@@ -183,36 +384,185 @@ func (core *Core) handleHTTPRequest(c *Context) {
 		unescape = core.UnescapePathValues
 	}
 
-	t := core.trees
-	for i, tl := 0, len(t); i < tl; i++ {
-		if t[i].method == method {
-			root := t[i].root
-			handlers, params, _ := root.getValue(path, c.Params, unescape)
-			if handlers != nil {
-				c.handlers = handlers
-				c.Params = params
-				c.Next()
-				return
+	trees := core.trees
+	var host *hostRouter
+	if len(core.hosts) > 0 {
+		// host params go before the path params
+		if host, c.Params = core.matchHost(c.Host(), c.Params); host != nil {
+			trees = host.trees
+		}
+	}
+
+	root := trees.get(method)
+	var (
+		handlers HandlersChain
+		params   Params
+		tsr      bool
+	)
+	if root != nil {
+		handlers, params, tsr = root.getValue(path, c.Params, unescape)
+	}
+	// HEAD is served by the GET route, without body, and redirected like GET
+	var get *node
+	if handlers == nil && method == http.MethodHead {
+		if get = trees.get(http.MethodGet); get != nil {
+			var getTSR bool
+			if handlers, params, getTSR = get.getValue(path, c.Params, unescape); handlers != nil {
+				c.Writer = &headWriter{ResponseWriter: c.Writer}
 			}
+			tsr = tsr || getTSR
+			if root == nil {
+				root = get
+			}
+		}
+	}
+
+	if handlers != nil {
+		c.handlers = handlers
+		c.Params = params
+		if core.RequestBody.enabled() {
+			c.handlers = append(HandlersChain{core.limitBody}, handlers...)
+		}
+		c.Next()
+		c.memWriter.WriteHeaderNow()
+		return
+	}
+	if root != nil && method != http.MethodConnect && path != "/" {
+		if tsr && core.RedirectTrailingSlash {
+			redirectTrailingSlash(c, path)
+			return
+		}
+		if core.RedirectFixedPath && (redirectFixedPath(c, root, path, core.RedirectTrailingSlash) ||
+			get != nil && get != root && redirectFixedPath(c, get, path, core.RedirectTrailingSlash)) {
+			return
+		}
+	}
+
+	if method == http.MethodOptions && core.HandleOPTIONS {
+		if allow := core.allowed(trees, path, method, unescape); allow != "" {
+			c.Writer.Header().Set("Allow", allow)
+			c.handlers = core.combineHandlers(HandlersChain{answerOptions})
+			c.Next()
+			c.memWriter.WriteHeaderNow()
+			return
 		}
 	}
 
 	if core.HandleMethodNotAllowed {
-		for _, tree := range core.trees {
-			if tree.method == method {
-				continue
-			}
+		if allow := core.allowed(trees, path, method, unescape); allow != "" {
+			c.Writer.Header().Set("Allow", allow)
+			c.handlers = core.combineHandlers(core.noMethod)
+			serverError(c, http.StatusMethodNotAllowed, default405Body)
+			return
+		}
+	}
 
-			handlers, _, _ := tree.root.getValue(path, c.Params, unescape)
-			if handlers != nil {
-				serverError(c, http.StatusMethodNotAllowed, default405Body)
-				return
+	if nr := core.findNoRoute(host, path); nr != nil {
+		c.handlers = nr.group.combineHandlers(nr.handlers)
+	} else {
+		c.handlers = core.Handlers
+	}
+	serverError(c, http.StatusNotFound, default404Body)
+}
+
+// notFound answers 404 from within a route, e.g. for a missing static file,
+// by the not-found handlers of the path. The middleware already ran.
+func notFound(c *Context) {
+	if nr := c.core.findNoRoute(c.core.hostOf(c), c.Request.URL.Path); nr != nil {
+		c.handlers = nr.handlers
+		c.index = -1
+	}
+	serverError(c, http.StatusNotFound, default404Body)
+	c.Abort()
+}
+
+// allowed returns the comma separated methods with a route for path in
+// trees, or "" if there is none. OPTIONS is included if HandleOPTIONS answers
+// it, HEAD if a GET route serves it.
+func (core *Core) allowed(trees methodTrees, path, reqMethod string, unescape bool) string {
+	methods := make([]string, 0, len(trees)+1)
+	hasOptions, hasGet, hasHead := false, false, false
+	for _, tree := range trees {
+		if tree.method == reqMethod {
+			continue
+		}
+		if handlers, _, _ := tree.root.getValue(path, nil, unescape); handlers != nil {
+			methods = append(methods, tree.method)
+			switch tree.method {
+			case http.MethodOptions:
+				hasOptions = true
+			case http.MethodGet:
+				hasGet = true
+			case http.MethodHead:
+				hasHead = true
 			}
 		}
 	}
+	if len(methods) == 0 {
+		return ""
+	}
 
-	serverError(c, http.StatusNotFound, default404Body)
-	return
+	if core.HandleOPTIONS && !hasOptions {
+		methods = append(methods, http.MethodOptions)
+	}
+	if hasGet && !hasHead && reqMethod != http.MethodHead {
+		methods = append(methods, http.MethodHead)
+	}
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
+
+func answerOptions(c *Context) {
+	c.AbortWithStatus(http.StatusNoContent)
+}
+
+func redirectTrailingSlash(c *Context, p string) {
+	if length := len(p); length > 1 && p[length-1] == '/' {
+		p = p[:length-1]
+	} else {
+		p += "/"
+	}
+
+	redirectRequest(c, p)
+}
+
+func redirectFixedPath(c *Context, root *node, p string, trailingSlash bool) bool {
+	fixedPath, found := root.findCaseInsensitivePath(cleanPath(p), trailingSlash)
+	if !found {
+		return false
+	}
+
+	redirectRequest(c, string(fixedPath))
+	return true
+}
+
+// redirectRequest redirects to p, which is the escaped path if the route was
+// looked up by URL.RawPath. GET and HEAD requests get 301, any other method 308 so the
+// client repeats the request with the same method and body.
+func redirectRequest(c *Context, p string) {
+	// a leading "//" would redirect to another host
+	if strings.HasPrefix(p, "//") {
+		p = "/" + strings.TrimLeft(p, "/")
+	}
+
+	u := *c.Request.URL
+	if len(u.RawPath) > 0 && c.core.UseRawPath {
+		u.RawPath = p
+		if unescaped, err := url.PathUnescape(p); err == nil {
+			u.Path = unescaped
+		}
+	} else {
+		u.Path = p
+		u.RawPath = ""
+	}
+
+	code := http.StatusMovedPermanently
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		code = http.StatusPermanentRedirect
+	}
+
+	http.Redirect(c.Writer, c.Request, u.RequestURI(), code)
+	c.memWriter.WriteHeaderNow()
 }
 
 func serverError(c *Context, code int, defaultMessage []byte) {
@@ -0,0 +1,162 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRoutesNameAndMeta(t *testing.T) {
+	core := New()
+	g1, g2 := core.Group("/a"), core.Group("/b")
+
+	users := g1.GET("/users", func(c *Context) {})
+	g2.GET("/posts", func(c *Context) {})
+	g2.UseMiddleware(func(c *Context) {})
+	users.Name("users").Meta("auth", "admin")
+
+	routes := core.Routes()
+	for _, info := range routes {
+		switch info.Path {
+		case "/a/users":
+			if info.Name != "users" || info.Metadata["auth"] != "admin" {
+				t.Errorf("%s: name %q metadata %v, want users and auth=admin", info.Path, info.Name, info.Metadata)
+			}
+			info.Metadata["auth"] = "none"
+		case "/b/posts":
+			if info.Name != "" || info.Metadata != nil {
+				t.Errorf("%s: name %q metadata %v, want none", info.Path, info.Name, info.Metadata)
+			}
+		}
+	}
+
+	for _, info := range core.Routes() {
+		if info.Path == "/a/users" && info.Metadata["auth"] != "admin" {
+			t.Errorf("Routes shares metadata with the router, got auth=%v", info.Metadata["auth"])
+		}
+	}
+}
+
+func TestGroupNameWithoutRoute(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("naming a group without route did not panic")
+		}
+	}()
+	New().Group("/a").Name("a")
+}
+
+func TestNoRouteScope(t *testing.T) {
+	var trace []string
+	mark := func(name string) HandlerFunc {
+		return func(c *Context) { trace = append(trace, name) }
+	}
+
+	core := New()
+	core.UseMiddleware(mark("global"))
+	core.NoRoute(func(c *Context) { c.String(http.StatusNotFound, "core") })
+	api := core.Group("/api", mark("api"))
+	api.NoRoute(func(c *Context) { c.String(http.StatusNotFound, "api") })
+	v1 := api.Group("/v1", mark("v1"))
+	v1.NoRoute(func(c *Context) { c.JSON(http.StatusGone, "v1") })
+	v1.GET("/users", func(c *Context) {})
+	v1.StaticIOFS("/assets", fstest.MapFS{"app.js": {Data: []byte("app")}})
+	core.Group("/apiary").GET("/bees", func(c *Context) {})
+
+	tests := []struct {
+		path  string
+		code  int
+		body  string
+		trace string
+	}{
+		{"/missing", http.StatusNotFound, "core", "global"},
+		{"/api", http.StatusNotFound, "api", "global,api"},
+		{"/api/missing", http.StatusNotFound, "api", "global,api"},
+		// "/apiary" is no path below "/api"
+		{"/apiary/missing", http.StatusNotFound, "core", "global"},
+		{"/api/v1/missing", http.StatusGone, `"v1"`, "global,api,v1"},
+		{"/api/v1/users/1", http.StatusGone, `"v1"`, "global,api,v1"},
+		// the static handler answers missing files by the not-found handlers
+		// of the path, the middleware ran once before it
+		{"/api/v1/assets/app.js", http.StatusOK, "app", "global,api,v1"},
+		{"/api/v1/assets/missing.js", http.StatusGone, `"v1"`, "global,api,v1"},
+	}
+	for _, tt := range tests {
+		trace = nil
+		w := httptest.NewRecorder()
+		core.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if w.Code != tt.code || w.Body.String() != tt.body {
+			t.Errorf("%s: got %d %q, want %d %q", tt.path, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+		if got := strings.Join(trace, ","); got != tt.trace {
+			t.Errorf("%s: handlers ran %q, want %q", tt.path, got, tt.trace)
+		}
+	}
+}
+
+func TestNoRouteDefault(t *testing.T) {
+	core := New()
+	core.UseMiddleware(func(c *Context) { c.Writer.Header().Set("X-Middleware", "1") })
+	core.GET("/users", func(c *Context) {})
+
+	w := httptest.NewRecorder()
+	core.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	if w.Code != http.StatusNotFound || w.Body.String() != string(default404Body) || w.Header().Get("X-Middleware") != "1" {
+		t.Errorf("got %d %q with middleware header %q, want the default 404 after the middleware",
+			w.Code, w.Body.String(), w.Header().Get("X-Middleware"))
+	}
+}
+
+func TestNoMethod(t *testing.T) {
+	for _, handle := range []bool{true, false} {
+		var trace []string
+		core := New()
+		core.HandleMethodNotAllowed = handle
+		core.UseMiddleware(func(c *Context) { trace = append(trace, "global") })
+		core.NoMethod(func(c *Context) {
+			trace = append(trace, "noMethod")
+			c.String(c.Writer.Status(), "no method")
+		})
+		core.NoRoute(func(c *Context) {
+			trace = append(trace, "noRoute")
+			c.String(c.Writer.Status(), "no route")
+		})
+		core.GET("/users", func(c *Context) {})
+		core.POST("/users", func(c *Context) {})
+
+		w := httptest.NewRecorder()
+		core.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/users", nil))
+
+		code, body, allow, want := http.StatusMethodNotAllowed, "no method", "GET, HEAD, OPTIONS, POST", "global,noMethod"
+		if !handle {
+			code, body, allow, want = http.StatusNotFound, "no route", "", "global,noRoute"
+		}
+		if w.Code != code || w.Body.String() != body {
+			t.Errorf("HandleMethodNotAllowed %v: got %d %q, want %d %q", handle, w.Code, w.Body.String(), code, body)
+		}
+		if got := sortedAllow(w.Header().Get("Allow")); got != allow {
+			t.Errorf("HandleMethodNotAllowed %v: Allow %q, want %q", handle, got, allow)
+		}
+		if got := strings.Join(trace, ","); got != want {
+			t.Errorf("HandleMethodNotAllowed %v: handlers ran %q, want %q", handle, got, want)
+		}
+	}
+}
+
+// sortedAllow sorts the methods of an Allow header.
+func sortedAllow(allow string) string {
+	if allow == "" {
+		return ""
+	}
+	methods := strings.Split(allow, ", ")
+	sort.Strings(methods)
+	return strings.Join(methods, ", ")
+}
@@ -50,15 +50,20 @@ func LoggerWithWriter(except ...string) HandlerFunc {
 				path += "?" + raw
 			}
 
-			lFunc := logFuncForStatus(statusCode)
-			lFunc(map[string]interface{}{
+			fields := map[string]interface{}{
 				"clientIP":   clientIP,
 				"method":     method,
 				"path":       path,
 				"statusCode": statusCode,
 				"time":       end.Format("2006/01/02 15:04:05"),
 				"useTime":    fmt.Sprintf("%v", useTime),
-			})
+			}
+			if len(c.Errors) > 0 {
+				fields["errors"] = c.Errors.String()
+			}
+
+			lFunc := logFuncForStatus(statusCode)
+			lFunc(fields)
 
 		}
 	}
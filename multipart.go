@@ -0,0 +1,141 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+)
+
+// ErrPartTooLarge is returned by reads of a multipart part exceeding its
+// limit, it matches ErrBodyTooLarge by errors.Is.
+var ErrPartTooLarge = fmt.Errorf("klyn: multipart part too large: %w", ErrBodyTooLarge)
+
+// MultipartForm parses the multipart form of the request, files beyond
+// Core.MaxMultipartMemory are stored on disk.
+func (c *Context) MultipartForm() (*multipart.Form, error) {
+	if c.Request.MultipartForm == nil {
+		if err := c.Request.ParseMultipartForm(multipartMemory(c.core.MaxMultipartMemory)); err != nil {
+			return nil, err
+		}
+	}
+	return c.Request.MultipartForm, nil
+}
+
+// FormFile returns the first file of the multipart form key.
+func (c *Context) FormFile(name string) (*multipart.FileHeader, error) {
+	if _, err := c.MultipartForm(); err != nil {
+		return nil, err
+	}
+
+	f, fh, err := c.Request.FormFile(name)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+	return fh, nil
+}
+
+// SaveUploadedFile writes the uploaded file to dst, the directory of dst is
+// created if missing.
+func (c *Context) SaveUploadedFile(fh *multipart.FileHeader, dst string) error {
+	src, err := fh.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err = os.MkdirAll(filepath.Dir(dst), 0750); err != nil {
+		return err
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// MultipartReader returns an iterator over the parts of a multipart body,
+// which are streamed from the connection without buffering. Reading more
+// than maxPartSize bytes of a part fails with ErrPartTooLarge, 0 means no
+// limit. The request body limits of Core.RequestBody still apply.
+//
+//	mr, err := c.MultipartReader(100 << 20)
+//	for err == nil {
+//		var part *klyn.Part
+//		if part, err = mr.Next(); err == nil {
+//			_, err = io.Copy(dst, part)
+//		}
+//	}
+//	if err != io.EOF { ... }
+func (c *Context) MultipartReader(maxPartSize int64) (*PartReader, error) {
+	r, err := c.Request.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	return &PartReader{r: r, maxPartSize: maxPartSize}, nil
+}
+
+// PartReader iterates the parts of a multipart body, see
+// Context.MultipartReader.
+type PartReader struct {
+	r           *multipart.Reader
+	maxPartSize int64
+	part        *Part
+}
+
+// Next returns the next part, the previous part is closed. It returns io.EOF
+// after the last part.
+func (pr *PartReader) Next() (*Part, error) {
+	if pr.part != nil {
+		pr.part.Close()
+		pr.part = nil
+	}
+
+	p, err := pr.r.NextPart()
+	if err != nil {
+		return nil, err
+	}
+	pr.part = &Part{Part: p, max: pr.maxPartSize}
+	return pr.part, nil
+}
+
+// Part is a part of a multipart body, use FormName and FileName to tell
+// form values and files apart.
+type Part struct {
+	*multipart.Part
+	max  int64
+	read int64
+}
+
+func (p *Part) Read(b []byte) (int, error) {
+	if p.max > 0 {
+		if p.read >= p.max {
+			// probe for more data beyond the limit
+			var probe [1]byte
+			n, err := p.Part.Read(probe[:])
+			if n > 0 {
+				return 0, ErrPartTooLarge
+			}
+			return 0, err
+		}
+		if rest := p.max - p.read; int64(len(b)) > rest {
+			b = b[:rest]
+		}
+	}
+
+	n, err := p.Part.Read(b)
+	p.read += int64(n)
+	return n, err
+}
@@ -0,0 +1,149 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// multipartRequest returns a POST request of the form fields and files, the
+// keys of files are "field/filename".
+func multipartRequest(fields, files map[string]string) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for name, value := range fields {
+		mw.WriteField(name, value)
+	}
+	for key, content := range files {
+		i := strings.IndexByte(key, '/')
+		fw, _ := mw.CreateFormFile(key[:i], key[i+1:])
+		fw.Write([]byte(content))
+	}
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestFormFile(t *testing.T) {
+	dir := t.TempDir()
+	content := strings.Repeat("x", 64)
+
+	for _, maxMemory := range []int64{defaultMultipartMemory, 16} {
+		core := New()
+		core.MaxMultipartMemory = maxMemory
+		core.POST("/", func(c *Context) {
+			fh, err := c.FormFile("upload")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fh.Filename != "a.txt" || fh.Size != int64(len(content)) {
+				t.Errorf("memory %d: file %q of %d bytes, want a.txt of %d", maxMemory, fh.Filename, fh.Size, len(content))
+			}
+			if c.PostForm("name") != "klyn" {
+				t.Errorf("memory %d: PostForm(name) = %q, want klyn", maxMemory, c.PostForm("name"))
+			}
+
+			// files beyond MaxMultipartMemory are stored on disk
+			f, err := fh.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, onDisk := f.(*os.File)
+			f.Close()
+			if want := maxMemory < int64(len(content)); onDisk != want {
+				t.Errorf("memory %d: file on disk %v, want %v", maxMemory, onDisk, want)
+			}
+
+			dst := filepath.Join(dir, "nested", "dir", "a.txt")
+			if err = c.SaveUploadedFile(fh, dst); err != nil {
+				t.Fatal(err)
+			}
+			if saved, _ := os.ReadFile(dst); string(saved) != content {
+				t.Errorf("memory %d: saved %q, want %q", maxMemory, saved, content)
+			}
+
+			if _, err = c.FormFile("missing"); !errors.Is(err, http.ErrMissingFile) {
+				t.Errorf("memory %d: FormFile(missing) error = %v, want http.ErrMissingFile", maxMemory, err)
+			}
+		})
+
+		req := multipartRequest(map[string]string{"name": "klyn"}, map[string]string{"upload/a.txt": content})
+		core.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// FormFile of a body which is no multipart form fails
+	core := New()
+	core.POST("/", func(c *Context) {
+		if _, err := c.FormFile("upload"); !errors.Is(err, http.ErrNotMultipart) {
+			t.Errorf("FormFile of a urlencoded form error = %v, want http.ErrNotMultipart", err)
+		}
+	})
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("upload=1"))
+	req.Header.Set("Content-Type", MIMEPOSTForm)
+	core.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestMultipartReader(t *testing.T) {
+	tests := []struct {
+		maxPartSize int64
+		parts       map[string]string // "name" or "field/filename" to the content read
+		err         error
+	}{
+		{0, map[string]string{"name": "klyn", "upload/a.txt": strings.Repeat("x", 100)}, nil},
+		{100, map[string]string{"name": "klyn", "upload/a.txt": strings.Repeat("x", 100)}, nil},
+		{99, map[string]string{"name": "klyn"}, ErrPartTooLarge},
+	}
+	for _, tt := range tests {
+		core := New()
+		core.POST("/", func(c *Context) {
+			mr, err := c.MultipartReader(tt.maxPartSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := make(map[string]string)
+			var part *Part
+			for err == nil {
+				if part, err = mr.Next(); err == nil {
+					var b []byte
+					if b, err = io.ReadAll(part); err == nil {
+						key := part.FormName()
+						if part.FileName() != "" {
+							key += "/" + part.FileName()
+						}
+						got[key] = string(b)
+					}
+				}
+			}
+
+			if tt.err == nil && err != io.EOF || tt.err != nil && !errors.Is(err, tt.err) {
+				t.Errorf("max %d: error %v, want %v", tt.maxPartSize, err, tt.err)
+			}
+			if tt.err != nil && !errors.Is(err, ErrBodyTooLarge) {
+				t.Errorf("max %d: error %v does not match ErrBodyTooLarge", tt.maxPartSize, err)
+			}
+			for key, want := range tt.parts {
+				if got[key] != want {
+					t.Errorf("max %d: part %s = %q, want %q", tt.maxPartSize, key, got[key], want)
+				}
+			}
+		})
+
+		fields := map[string]string{"name": "klyn"}
+		files := map[string]string{"upload/a.txt": strings.Repeat("x", 100)}
+		core.ServeHTTP(httptest.NewRecorder(), multipartRequest(fields, files))
+	}
+}
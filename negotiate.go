@@ -0,0 +1,140 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Negotiate describes the formats a handler can respond with, see Context.Negotiate.
+type Negotiate struct {
+	// Offered are the MIME types of the response, in order of preference.
+	Offered []string
+	// Data is rendered in the chosen format.
+	Data interface{}
+	// FormatData overrides Data for the given MIME types.
+	FormatData map[string]interface{}
+	// HTMLName is the template rendered for text/html.
+	HTMLName string
+}
+
+// Negotiate renders the response in the offered format which fits the Accept
+// header of the request best. If none fits the chain is aborted with 406.
+func (c *Context) Negotiate(code int, n Negotiate) {
+	format := c.NegotiateFormat(n.Offered...)
+	// the offer may have parameters, e.g. "application/json; charset=utf-8"
+	mime := strings.ToLower(filterFlags(format))
+
+	data := n.Data
+	if d, ok := n.FormatData[format]; ok {
+		data = d
+	} else if d, ok := n.FormatData[mime]; ok {
+		data = d
+	}
+
+	switch {
+	case format == "":
+		c.AbortWithStatus(http.StatusNotAcceptable)
+	case mime == MIMEHTML && c.core.renders[MIMEHTML] == nil:
+		c.HTML(code, n.HTMLName, data)
+	default:
+		r := c.renderFor(mime, data)
+		if r == nil {
+			panic("klyn: no render registered for offered format '" + format + "'")
+		}
+		c.Render(code, r)
+	}
+}
+
+// NegotiateFormat returns the offered MIME type which fits the Accept header
+// best, or "" if none is acceptable. Without Accept header the first offer is
+// returned.
+func (c *Context) NegotiateFormat(offered ...string) string {
+	assert1(len(offered) > 0, "you must provide at least one offer")
+
+	accept := c.GetHeader("Accept")
+	if accept == "" {
+		return offered[0]
+	}
+
+	return negotiateFormat(parseAccept(accept), offered)
+}
+
+type acceptRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// parseAccept parses an Accept header, e.g. "text/html, application/*;q=0.8".
+func parseAccept(header string) []acceptRange {
+	parts := strings.Split(header, ",")
+	ranges := make([]acceptRange, 0, len(parts))
+	for _, part := range parts {
+		params := strings.Split(part, ";")
+		mediaRange := strings.ToLower(strings.TrimSpace(params[0]))
+		if mediaRange == "" {
+			continue
+		}
+		if mediaRange == "*" {
+			mediaRange = "*/*"
+		}
+		slash := strings.IndexByte(mediaRange, '/')
+		if slash < 0 {
+			continue
+		}
+
+		ar := acceptRange{typ: mediaRange[:slash], subtype: mediaRange[slash+1:], q: 1}
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if len(param) > 2 && (param[0] == 'q' || param[0] == 'Q') && param[1] == '=' {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil && q >= 0 && q <= 1 {
+					ar.q = q
+				}
+			}
+		}
+		ranges = append(ranges, ar)
+	}
+
+	return ranges
+}
+
+// negotiateFormat picks the offer with the highest quality. The quality of an
+// offer is the one of the most specific range matching it, ties are broken
+// by the order of the offers.
+func negotiateFormat(ranges []acceptRange, offered []string) string {
+	best, bestQ := "", 0.0
+	for _, offer := range offered {
+		mime := strings.ToLower(filterFlags(offer))
+		slash := strings.IndexByte(mime, '/')
+		if slash < 0 {
+			continue
+		}
+		typ, subtype := mime[:slash], mime[slash+1:]
+
+		q, specificity := 0.0, -1
+		for _, ar := range ranges {
+			s := -1
+			switch {
+			case ar.typ == typ && ar.subtype == subtype:
+				s = 2
+			case ar.typ == typ && ar.subtype == "*":
+				s = 1
+			case ar.typ == "*" && ar.subtype == "*":
+				s = 0
+			}
+			if s > specificity {
+				q, specificity = ar.q, s
+			}
+		}
+
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	return best
+}
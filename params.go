@@ -0,0 +1,131 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// ErrInvalidParam is returned by the typed param accessors for values of
+// the wrong format.
+var ErrInvalidParam = errors.New("klyn: invalid route param")
+
+// paramConstraint reports whether a param value is allowed by the
+// constraint of a route pattern, e.g. "/users/:id<int>".
+type paramConstraint func(value string) bool
+
+// builtinConstraints are the named constraints, any other constraint is a
+// regular expression matching the whole value, e.g. ":slug<[a-z-]+>".
+var builtinConstraints = map[string]paramConstraint{
+	"int":   func(v string) bool { _, err := strconv.ParseInt(v, 10, 64); return err == nil },
+	"uint":  func(v string) bool { _, err := strconv.ParseUint(v, 10, 64); return err == nil },
+	"uuid":  func(v string) bool { _, err := parseUUID(v); return err == nil },
+	"alpha": regexp.MustCompile(`^[a-zA-Z]+$`).MatchString,
+}
+
+// constraints caches the compiled constraints by expression.
+var constraints sync.Map
+
+func compileConstraint(expr string) (paramConstraint, error) {
+	if c, ok := builtinConstraints[expr]; ok {
+		return c, nil
+	}
+	if c, ok := constraints.Load(expr); ok {
+		return c.(paramConstraint), nil
+	}
+
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, err
+	}
+	c := paramConstraint(re.MatchString)
+	constraints.Store(expr, c)
+	return c, nil
+}
+
+// ParamInt returns the path param name as int.
+func (c *Context) ParamInt(name string) (int, error) {
+	value, err := c.param(name)
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := strconv.ParseInt(value, 10, 0)
+	if err != nil {
+		return 0, fmt.Errorf("%w '%s': %v", ErrInvalidParam, name, err)
+	}
+	return int(v), nil
+}
+
+// ParamInt64 returns the path param name as int64.
+func (c *Context) ParamInt64(name string) (int64, error) {
+	value, err := c.param(name)
+	if err != nil {
+		return 0, err
+	}
+
+	v, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w '%s': %v", ErrInvalidParam, name, err)
+	}
+	return v, nil
+}
+
+// ParamUUID returns the path param name as UUID.
+func (c *Context) ParamUUID(name string) (UUID, error) {
+	value, err := c.param(name)
+	if err != nil {
+		return UUID{}, err
+	}
+
+	u, err := parseUUID(value)
+	if err != nil {
+		return UUID{}, fmt.Errorf("%w '%s': %v", ErrInvalidParam, name, err)
+	}
+	return u, nil
+}
+
+func (c *Context) param(name string) (string, error) {
+	value, ok := c.Params.Get(name)
+	if !ok {
+		return "", fmt.Errorf("%w '%s'", ErrMissingParam, name)
+	}
+	return value, nil
+}
+
+// UUID is a RFC 4122 UUID, e.g. "f47ac10b-58cc-4372-a567-0e02b2c3d479".
+type UUID [16]byte
+
+func (u UUID) String() string {
+	var buf [36]byte
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf[:])
+}
+
+// parseUUID parses the canonical 8-4-4-4-12 hex form of a UUID.
+func parseUUID(s string) (u UUID, err error) {
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("invalid UUID '%s'", s)
+	}
+
+	src := s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	if _, err = hex.Decode(u[:], []byte(src)); err != nil {
+		return u, fmt.Errorf("invalid UUID '%s'", s)
+	}
+	return u, nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParamConstraints(t *testing.T) {
+	const uuid = "f47ac10b-58cc-4372-a567-0e02b2c3d479"
+
+	core := New()
+	route := func(path string) HandlerFunc {
+		return func(c *Context) { c.String(http.StatusOK, path) }
+	}
+	core.GET("/users/:id<int>", route("/users/:id<int>"))
+	core.GET("/users/:name", route("/users/:name"))
+	core.GET("/items/:id<uuid>", route("/items/:id<uuid>"))
+	core.GET("/posts/:slug<[a-z]+(-[a-z]+)*>", route("/posts/:slug<[a-z]+(-[a-z]+)*>"))
+	core.GET("/codes/:code<alpha>", route("/codes/:code<alpha>"))
+
+	tests := []struct {
+		path, route string // route is "" for 404
+	}{
+		{"/users/42", "/users/:id<int>"},
+		{"/users/-7", "/users/:id<int>"},
+		{"/users/bob", "/users/:name"},
+		{"/users/42x", "/users/:name"},
+		{"/items/" + uuid, "/items/:id<uuid>"},
+		{"/items/f47ac10b58cc4372a5670e02b2c3d479", ""},
+		{"/items/g47ac10b-58cc-4372-a567-0e02b2c3d479", ""},
+		{"/posts/hello-world", "/posts/:slug<[a-z]+(-[a-z]+)*>"},
+		{"/posts/hello-", ""},
+		{"/posts/Hello", ""},
+		{"/codes/ab", "/codes/:code<alpha>"},
+		{"/codes/a1", ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		core.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+		if tt.route == "" {
+			if w.Code != http.StatusNotFound {
+				t.Errorf("%s: status %d, want 404", tt.path, w.Code)
+			}
+			continue
+		}
+		if w.Code != http.StatusOK || w.Body.String() != tt.route {
+			t.Errorf("%s: got %d %q, want route %q", tt.path, w.Code, w.Body.String(), tt.route)
+		}
+	}
+}
+
+func TestInvalidConstraintPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("registering an invalid constraint did not panic")
+		}
+	}()
+	New().GET("/users/:id<[a-z>", func(c *Context) {})
+}
+
+func TestTypedParams(t *testing.T) {
+	c := &Context{Params: Params{
+		{Key: "id", Value: "42"},
+		{Key: "neg", Value: "-3"},
+		{Key: "big", Value: "9223372036854775807"},
+		{Key: "overflow", Value: "9223372036854775808"},
+		{Key: "name", Value: "bob"},
+		{Key: "uuid", Value: "F47AC10B-58CC-4372-A567-0E02B2C3D479"},
+	}}
+
+	if v, err := c.ParamInt("id"); v != 42 || err != nil {
+		t.Errorf("ParamInt(id) = (%d, %v), want 42", v, err)
+	}
+	if v, err := c.ParamInt("neg"); v != -3 || err != nil {
+		t.Errorf("ParamInt(neg) = (%d, %v), want -3", v, err)
+	}
+	if v, err := c.ParamInt64("big"); v != 1<<63-1 || err != nil {
+		t.Errorf("ParamInt64(big) = (%d, %v), want MaxInt64", v, err)
+	}
+	for _, name := range []string{"overflow", "name"} {
+		if v, err := c.ParamInt(name); v != 0 || !errors.Is(err, ErrInvalidParam) {
+			t.Errorf("ParamInt(%s) = (%d, %v), want ErrInvalidParam", name, v, err)
+		}
+		if v, err := c.ParamInt64(name); v != 0 || !errors.Is(err, ErrInvalidParam) {
+			t.Errorf("ParamInt64(%s) = (%d, %v), want ErrInvalidParam", name, v, err)
+		}
+	}
+
+	if u, err := c.ParamUUID("uuid"); err != nil || u.String() != "f47ac10b-58cc-4372-a567-0e02b2c3d479" {
+		t.Errorf("ParamUUID(uuid) = (%s, %v)", u, err)
+	}
+	if _, err := c.ParamUUID("name"); !errors.Is(err, ErrInvalidParam) {
+		t.Errorf("ParamUUID(name) error = %v, want ErrInvalidParam", err)
+	}
+
+	if _, err := c.ParamInt("missing"); !errors.Is(err, ErrMissingParam) {
+		t.Errorf("ParamInt(missing) error = %v, want ErrMissingParam", err)
+	}
+	if _, err := c.ParamUUID("missing"); !errors.Is(err, ErrMissingParam) {
+		t.Errorf("ParamUUID(missing) error = %v, want ErrMissingParam", err)
+	}
+}
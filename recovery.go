@@ -0,0 +1,104 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strings"
+	"syscall"
+
+	klynlog "github.com/yusank/klyn-log"
+)
+
+// RecoveryFunc handles a recovered panic, err is the value passed to panic.
+type RecoveryFunc func(c *Context, err interface{})
+
+// Recovery returns a middleware that recovers from any panic and responds with 500.
+func Recovery() HandlerFunc {
+	return CustomRecoveryWithLogger(defaultKlynLog, defaultHandleRecovery)
+}
+
+// CustomRecovery is like Recovery but lets handle build the response.
+func CustomRecovery(handle RecoveryFunc) HandlerFunc {
+	return CustomRecoveryWithLogger(defaultKlynLog, handle)
+}
+
+// CustomRecoveryWithLogger recovers from any panic, logs the stack to logger
+// and calls handle. Panics caused by a broken connection are only logged,
+// since nothing can be written back to the client anymore.
+func CustomRecoveryWithLogger(logger klynlog.Logger, handle RecoveryFunc) HandlerFunc {
+	if handle == nil {
+		handle = defaultHandleRecovery
+	}
+
+	return func(c *Context) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+
+			brokenPipe := isBrokenPipe(err)
+			entry := map[string]interface{}{
+				"panic":    fmt.Sprint(err),
+				"clientIP": c.ClientIP(),
+				"method":   c.Request.Method,
+				"path":     c.Request.URL.Path,
+				"handler":  c.HandlerName(),
+			}
+			if !brokenPipe {
+				entry["stack"] = string(debug.Stack())
+			}
+			if logger != nil {
+				logger.Error(entry)
+			}
+
+			if brokenPipe {
+				c.Abort()
+				return
+			}
+			handle(c, err)
+		}()
+
+		c.Next()
+	}
+}
+
+func defaultHandleRecovery(c *Context, _ interface{}) {
+	if c.Writer.Written() {
+		c.Abort()
+		return
+	}
+
+	c.AbortWithStatus(http.StatusInternalServerError)
+}
+
+// isBrokenPipe reports whether the panic was caused by a connection the
+// client already closed.
+func isBrokenPipe(err interface{}) bool {
+	e, ok := err.(error)
+	if !ok {
+		return false
+	}
+	if errors.Is(e, syscall.EPIPE) || errors.Is(e, syscall.ECONNRESET) {
+		return true
+	}
+
+	var ne *net.OpError
+	if errors.As(e, &ne) {
+		var se *os.SyscallError
+		if errors.As(ne, &se) {
+			msg := strings.ToLower(se.Error())
+			return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,318 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultSecureJSONPrefix = "while(1);"
+
+// Render writes the response body in a format.
+type Render interface {
+	// Render writes the body, the Content-Type is already set.
+	Render(http.ResponseWriter) error
+	// WriteContentType sets the Content-Type header of the format.
+	WriteContentType(http.ResponseWriter)
+}
+
+// RenderFactory builds the Render of a format for Context.Negotiate.
+type RenderFactory func(data interface{}) Render
+
+var (
+	_ Render = JSONRender{}
+	_ Render = IndentedJSONRender{}
+	_ Render = SecureJSONRender{}
+	_ Render = JSONPRender{}
+	_ Render = XMLRender{}
+	_ Render = YAMLRender{}
+	_ Render = TextRender{}
+	_ Render = HTMLRender{}
+	_ Render = MsgPackRender{}
+)
+
+// RegisterRender makes the format available to Context.Negotiate, it
+// overrides the builtin format of the same MIME type.
+func (core *Core) RegisterRender(mime string, factory RenderFactory) {
+	assert1(factory != nil, "render factory can not be nil")
+	if core.renders == nil {
+		core.renders = make(map[string]RenderFactory)
+	}
+	core.renders[strings.ToLower(filterFlags(mime))] = factory
+}
+
+// renderFor returns the Render of the negotiated format, or nil if there is
+// none for the MIME type.
+func (c *Context) renderFor(mime string, data interface{}) Render {
+	if factory, ok := c.core.renders[mime]; ok {
+		return factory(data)
+	}
+
+	switch mime {
+	case MIMEJSON:
+		return JSONRender{Codec: c.core.JSONCodec, Data: data}
+	case MIMEXML, MIMEXML2:
+		return XMLRender{Data: data}
+	case MIMEYAML:
+		return YAMLRender{Data: data}
+	case MIMEMsgPack:
+		return MsgPackRender{Data: data}
+	case MIMEPlain:
+		return TextRender{Format: "%v", Data: []interface{}{data}}
+	}
+	return nil
+}
+
+func writeContentType(w http.ResponseWriter, value string) {
+	header := w.Header()
+	if val := header["Content-Type"]; len(val) == 0 {
+		header["Content-Type"] = []string{value}
+	}
+}
+
+// JSONRender renders Data as json, streamed to the response by Codec.
+type JSONRender struct {
+	Codec JSONCodec
+	Data  interface{}
+}
+
+func (r JSONRender) Render(w http.ResponseWriter) error {
+	return codecOr(r.Codec).NewEncoder(&trimNewlineWriter{w: w}).Encode(r.Data)
+}
+
+// trimNewlineWriter drops the newline an encoder writes after the value, a
+// newline is only written once more data follows it.
+type trimNewlineWriter struct {
+	w       io.Writer
+	newline bool
+}
+
+func (t *trimNewlineWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if t.newline {
+		if _, err := t.w.Write([]byte{'\n'}); err != nil {
+			return 0, err
+		}
+		t.newline = false
+	}
+
+	n := len(p)
+	if p[n-1] == '\n' {
+		p, t.newline = p[:n-1], true
+	}
+	if len(p) > 0 {
+		if _, err := t.w.Write(p); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+func (r JSONRender) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, jsonContent)
+}
+
+// IndentedJSONRender renders Data as indented json.
+type IndentedJSONRender struct {
+	Codec JSONCodec
+	Data  interface{}
+}
+
+func (r IndentedJSONRender) Render(w http.ResponseWriter) error {
+	enc := codecOr(r.Codec).NewEncoder(&trimNewlineWriter{w: w})
+	enc.SetIndent("", "    ")
+	return enc.Encode(r.Data)
+}
+
+func (r IndentedJSONRender) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, jsonContent)
+}
+
+// SecureJSONRender renders Data as json, json arrays are prefixed to prevent
+// json hijacking.
+type SecureJSONRender struct {
+	Codec  JSONCodec
+	Prefix string
+	Data   interface{}
+}
+
+func (r SecureJSONRender) Render(w http.ResponseWriter) error {
+	body, err := codecOr(r.Codec).Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	if bytes.HasPrefix(body, []byte("[")) && bytes.HasSuffix(body, []byte("]")) {
+		if _, err = w.Write([]byte(r.Prefix)); err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+func (r SecureJSONRender) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, jsonContent)
+}
+
+// JSONPRender renders Data as json wrapped into the Callback function, plain
+// json is rendered if Callback is empty.
+type JSONPRender struct {
+	Codec    JSONCodec
+	Callback string
+	Data     interface{}
+}
+
+func (r JSONPRender) Render(w http.ResponseWriter) error {
+	body, err := codecOr(r.Codec).Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	if r.Callback == "" {
+		_, err = w.Write(body)
+		return err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(template.JSEscapeString(r.Callback))
+	buf.WriteByte('(')
+	buf.Write(body)
+	buf.WriteString(");")
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+func (r JSONPRender) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, javascriptContent)
+}
+
+// XMLRender renders Data as xml.
+type XMLRender struct {
+	Data interface{}
+}
+
+func (r XMLRender) Render(w http.ResponseWriter) error {
+	return xml.NewEncoder(w).Encode(r.Data)
+}
+
+func (r XMLRender) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, xmlContent)
+}
+
+// YAMLRender renders Data as yaml.
+type YAMLRender struct {
+	Data interface{}
+}
+
+func (r YAMLRender) Render(w http.ResponseWriter) error {
+	body, err := yaml.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+func (r YAMLRender) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, yamlContent)
+}
+
+// TextRender renders Format with fmt.Sprintf, or as is without Data.
+type TextRender struct {
+	Format string
+	Data   []interface{}
+}
+
+func (r TextRender) Render(w http.ResponseWriter) (err error) {
+	if len(r.Data) > 0 {
+		_, err = fmt.Fprintf(w, r.Format, r.Data...)
+		return
+	}
+	_, err = w.Write([]byte(r.Format))
+	return
+}
+
+func (r TextRender) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, plainContent)
+}
+
+// HTMLRender executes the template Name of Template, or Template itself if
+// Name is empty.
+type HTMLRender struct {
+	Template *template.Template
+	Name     string
+	Data     interface{}
+}
+
+func (r HTMLRender) Render(w http.ResponseWriter) error {
+	if r.Template == nil {
+		return fmt.Errorf("klyn: no html template loaded to render '%s'", r.Name)
+	}
+	if r.Name == "" {
+		return r.Template.Execute(w, r.Data)
+	}
+	return r.Template.ExecuteTemplate(w, r.Name, r.Data)
+}
+
+func (r HTMLRender) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, htmlContent)
+}
+
+// MsgPackRender renders Data as MessagePack.
+type MsgPackRender struct {
+	Data interface{}
+}
+
+func (r MsgPackRender) Render(w http.ResponseWriter) error {
+	body, err := msgpack.Marshal(r.Data)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+func (r MsgPackRender) WriteContentType(w http.ResponseWriter) {
+	writeContentType(w, MIMEMsgPack)
+}
+
+// SetHTMLTemplate sets the templates rendered by Context.HTML.
+func (core *Core) SetHTMLTemplate(t *template.Template) {
+	core.htmlTemplate = t
+}
+
+// LoadHTMLGlob parses the templates matched by pattern for Context.HTML.
+func (core *Core) LoadHTMLGlob(pattern string) {
+	core.htmlTemplate = template.Must(template.New("").Funcs(core.FuncMap).ParseGlob(pattern))
+}
+
+// LoadHTMLFiles parses the template files for Context.HTML.
+func (core *Core) LoadHTMLFiles(files ...string) {
+	core.htmlTemplate = template.Must(template.New("").Funcs(core.FuncMap).ParseFiles(files...))
+}
+
+// bodyAllowedForStatus reports whether a response with status may have a body,
+// see RFC 7230 section 3.3.
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent:
+		return false
+	case status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestContextRenders(t *testing.T) {
+	core := New()
+	core.SetHTMLTemplate(template.Must(template.New("hello").Parse("<p>{{.}}</p>")))
+	data := K{"a": 1}
+	core.GET("/json", func(c *Context) { c.JSON(http.StatusOK, data) })
+	core.GET("/indented", func(c *Context) { c.IndentedJSON(http.StatusOK, data) })
+	core.GET("/secure", func(c *Context) { c.SecureJSON(http.StatusOK, []int{1}) })
+	core.GET("/jsonp", func(c *Context) { c.JSONP(http.StatusOK, data) })
+	core.GET("/xml", func(c *Context) {
+		c.XML(http.StatusOK, struct {
+			XMLName struct{} `xml:"user"`
+			Name    string   `xml:"name"`
+		}{Name: "bob"})
+	})
+	core.GET("/yaml", func(c *Context) { c.YAML(http.StatusOK, data) })
+	core.GET("/text", func(c *Context) { c.String(http.StatusCreated, "%s-%d", "a", 1) })
+	core.GET("/html", func(c *Context) { c.HTML(http.StatusOK, "hello", "<b>") })
+	core.GET("/nobody", func(c *Context) { c.JSON(http.StatusNoContent, data) })
+	core.GET("/fail", func(c *Context) { c.JSON(http.StatusOK, func() {}) })
+
+	tests := []struct {
+		path, contentType, body string
+		code                    int
+	}{
+		{"/json", jsonContent, `{"a":1}`, http.StatusOK},
+		{"/indented", jsonContent, "{\n    \"a\": 1\n}", http.StatusOK},
+		{"/secure", jsonContent, "while(1);[1]", http.StatusOK},
+		{"/jsonp?callback=cb", javascriptContent, `cb({"a":1});`, http.StatusOK},
+		{"/jsonp", javascriptContent, `{"a":1}`, http.StatusOK},
+		{"/xml", xmlContent, "<user><name>bob</name></user>", http.StatusOK},
+		{"/yaml", yamlContent, "a: 1\n", http.StatusOK},
+		{"/text", plainContent, "a-1", http.StatusCreated},
+		{"/html", htmlContent, "<p>&lt;b&gt;</p>", http.StatusOK},
+		{"/nobody", jsonContent, "", http.StatusNoContent},
+		{"/fail", "", "", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		core.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.code || w.Body.String() != tt.body || w.Header().Get("Content-Type") != tt.contentType {
+			t.Errorf("%s: got %d %q %q, want %d %q %q", tt.path, w.Code, w.Header().Get("Content-Type"),
+				w.Body.String(), tt.code, tt.contentType, tt.body)
+		}
+	}
+}
+
+type negotiateXML struct {
+	A int
+}
+
+func TestNegotiate(t *testing.T) {
+	core := New()
+	core.RegisterRender("Application/Vnd.Klyn+Text", func(data interface{}) Render {
+		return TextRender{Format: "custom %v", Data: []interface{}{data}}
+	})
+	core.GET("/", func(c *Context) {
+		c.Negotiate(http.StatusOK, Negotiate{
+			Offered: []string{"application/json; charset=utf-8", "Application/XML", MIMEYAML, "application/vnd.klyn+text"},
+			Data:    K{"a": 1},
+			FormatData: map[string]interface{}{
+				"application/xml":           negotiateXML{A: 1},
+				"application/vnd.klyn+text": "data",
+			},
+		})
+	})
+
+	tests := []struct {
+		accept, contentType, body string
+		code                      int
+	}{
+		{"", jsonContent, `{"a":1}`, http.StatusOK},
+		{"*/*", jsonContent, `{"a":1}`, http.StatusOK},
+		{"application/xml", xmlContent, "<negotiateXML><A>1</A></negotiateXML>", http.StatusOK},
+		{"application/json;q=0.5, application/x-yaml", yamlContent, "a: 1\n", http.StatusOK},
+		{"application/*;q=0.2, application/xml;q=0.9", xmlContent, "", http.StatusOK},
+		{"text/plain, application/vnd.klyn+text;q=0.1", plainContent, "custom data", http.StatusOK},
+		{"image/png", "", "", http.StatusNotAcceptable},
+		{"application/json;q=0", "", "", http.StatusNotAcceptable},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		core.ServeHTTP(w, req)
+		if w.Code != tt.code || w.Header().Get("Content-Type") != tt.contentType {
+			t.Errorf("Accept %q: got %d %q, want %d %q", tt.accept, w.Code, w.Header().Get("Content-Type"), tt.code, tt.contentType)
+		}
+		if tt.code == http.StatusOK && tt.body != "" && !strings.HasPrefix(w.Body.String(), tt.body) {
+			t.Errorf("Accept %q: body %q, want %q", tt.accept, w.Body.String(), tt.body)
+		}
+	}
+}
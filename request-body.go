@@ -0,0 +1,228 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultMaxDecompressRatio = 100
+	// minRatioCheck is the decoded size from which the ratio is checked,
+	// small bodies may legitimately compress very well.
+	minRatioCheck = 1 << 16
+)
+
+// ErrBodyTooLarge is returned by reads of a request body exceeding its limits.
+var ErrBodyTooLarge = errors.New("klyn: request body too large")
+
+var (
+	default413Body = []byte("413 request entity too large")
+	default415Body = []byte("415 unsupported media type")
+)
+
+// RequestBodyConfig limits request bodies, see LimitRequestBody.
+type RequestBodyConfig struct {
+	// MaxSize is the maximum body size after decompression, 0 means no limit.
+	MaxSize int64
+	// Decompress decodes gzip and deflate encoded bodies, bodies of other
+	// encodings are answered with 415.
+	Decompress bool
+	// MaxRatio stops decoding once the body grows beyond MaxRatio times its
+	// encoded size, 100 by default.
+	MaxRatio int64
+}
+
+// LimitRequestBody returns a middleware applying cfg to the request body.
+// Bodies whose Content-Length exceeds MaxSize are answered with 413 right
+// away, otherwise reading beyond the limits fails with ErrBodyTooLarge and
+// the request is answered with 413 unless the handler already responded.
+// Core.RequestBody applies the same limits to all routes.
+func LimitRequestBody(cfg RequestBodyConfig) HandlerFunc {
+	return func(c *Context) {
+		body, code := limitRequestBody(c, cfg)
+		if code != 0 {
+			c.Abort()
+			serverError(c, code, rejectedBody(code))
+			return
+		}
+		c.Next()
+		body.finish(c)
+	}
+}
+
+// limitBody is the first handler of the routes if Core.RequestBody is set.
+// A rejected request is answered by the middleware of Core, like a request
+// without route.
+func (core *Core) limitBody(c *Context) {
+	body, code := limitRequestBody(c, core.RequestBody)
+	if code != 0 {
+		c.handlers = core.Handlers
+		c.index = -1
+		serverError(c, code, rejectedBody(code))
+		c.Abort()
+		return
+	}
+	c.Next()
+	body.finish(c)
+}
+
+func (cfg RequestBodyConfig) enabled() bool {
+	return cfg.MaxSize > 0 || cfg.Decompress
+}
+
+// limitRequestBody wraps the request body, it returns the status to answer
+// with if the request must be rejected, 0 otherwise.
+func limitRequestBody(c *Context, cfg RequestBodyConfig) (*limitedBody, int) {
+	req := c.Request
+	if req.Body == nil || req.Body == http.NoBody || !cfg.enabled() {
+		return nil, 0
+	}
+
+	if cfg.MaxSize > 0 && req.ContentLength > cfg.MaxSize {
+		return nil, http.StatusRequestEntityTooLarge
+	}
+
+	body := &limitedBody{
+		w:   c.Writer,
+		src: req.Body,
+		max: cfg.MaxSize,
+	}
+	if cfg.MaxSize > 0 {
+		body.src = http.MaxBytesReader(c.Writer, req.Body, cfg.MaxSize)
+	}
+
+	encoding := strings.ToLower(strings.TrimSpace(req.Header.Get("Content-Encoding")))
+	if cfg.Decompress && encoding != "" && encoding != "identity" {
+		switch encoding {
+		case "gzip", "x-gzip", "deflate":
+		default:
+			return nil, http.StatusUnsupportedMediaType
+		}
+
+		body.encoding = encoding
+		body.ratio = cfg.MaxRatio
+		if body.ratio <= 0 {
+			body.ratio = defaultMaxDecompressRatio
+		}
+		req.Header.Del("Content-Encoding")
+		req.Header.Del("Content-Length")
+		req.ContentLength = -1
+	}
+
+	req.Body = body
+	return body, 0
+}
+
+func rejectedBody(code int) []byte {
+	if code == http.StatusUnsupportedMediaType {
+		return default415Body
+	}
+	return default413Body
+}
+
+// limitedBody counts the encoded and decoded bytes of a request body.
+type limitedBody struct {
+	w        ResponseWriter // gets the 413 status once the body is too large
+	src      io.ReadCloser
+	encoding string
+	decoder  io.Reader
+	max      int64
+	ratio    int64
+
+	encoded  int64
+	decoded  int64
+	tooLarge bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.tooLarge {
+		return 0, ErrBodyTooLarge
+	}
+
+	var (
+		n   int
+		err error
+	)
+	if b.encoding == "" {
+		n, err = b.readSource(p)
+	} else {
+		if b.decoder == nil {
+			// the decoders read the header on creation, delay it to the
+			// first read so the errors surface to the handler
+			if b.decoder, err = b.newDecoder(); err != nil {
+				return 0, err
+			}
+		}
+		n, err = b.decoder.Read(p)
+		b.decoded += int64(n)
+	}
+
+	if b.tooLarge || b.max > 0 && b.decoded > b.max ||
+		b.ratio > 0 && b.decoded > minRatioCheck && b.decoded > b.ratio*b.encoded {
+		b.exceeded()
+		return n, ErrBodyTooLarge
+	}
+	return n, err
+}
+
+func (b *limitedBody) newDecoder() (io.Reader, error) {
+	src := readerFunc(b.readSource)
+	if b.encoding == "deflate" {
+		return zlib.NewReader(src)
+	}
+	return gzip.NewReader(src)
+}
+
+// readSource reads the encoded body, the errors of http.MaxBytesReader are
+// reported as ErrBodyTooLarge.
+func (b *limitedBody) readSource(p []byte) (int, error) {
+	n, err := b.src.Read(p)
+	b.encoded += int64(n)
+	if b.encoding == "" {
+		b.decoded = b.encoded
+	}
+
+	if err != nil && err != io.EOF && b.max > 0 && b.encoded >= b.max {
+		b.exceeded()
+		err = ErrBodyTooLarge
+	}
+	return n, err
+}
+
+// exceeded marks the body as too large and sets the 413 status right away,
+// so the middleware sees it even if the handlers do not respond.
+func (b *limitedBody) exceeded() {
+	if !b.tooLarge && !b.w.Written() {
+		b.w.WriteHeader(http.StatusRequestEntityTooLarge)
+	}
+	b.tooLarge = true
+}
+
+func (b *limitedBody) Close() error {
+	if closer, ok := b.decoder.(io.Closer); ok {
+		closer.Close()
+	}
+	return b.src.Close()
+}
+
+// finish answers with 413 if the body exceeded its limits and the handlers
+// did not respond.
+func (b *limitedBody) finish(c *Context) {
+	if b != nil && b.tooLarge && !c.Writer.Written() {
+		serverError(c, http.StatusRequestEntityTooLarge, default413Body)
+	}
+}
+
+type readerFunc func(p []byte) (int, error)
+
+func (f readerFunc) Read(p []byte) (int, error) {
+	return f(p)
+}
@@ -0,0 +1,85 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCoreRequestBodyRunsMiddleware(t *testing.T) {
+	core := New()
+	core.RequestBody = RequestBodyConfig{MaxSize: 4}
+
+	var logged []int
+	core.UseMiddleware(func(c *Context) {
+		c.Next()
+		logged = append(logged, c.Writer.Status())
+	})
+	handled := false
+	core.POST("/", func(c *Context) {
+		handled = true
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.String(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.String(http.StatusOK, string(body))
+	})
+	core.POST("/ignore", func(c *Context) {
+		handled = true
+		if _, err := io.ReadAll(c.Request.Body); err == nil {
+			c.Status(http.StatusNoContent)
+		}
+	})
+
+	tests := []struct {
+		path     string
+		body     string
+		encoding string
+		chunked  bool
+		code     int
+		handled  bool
+	}{
+		{path: "/", body: "abc", code: http.StatusOK, handled: true},
+		{path: "/", body: "too large", code: http.StatusRequestEntityTooLarge},
+		{path: "/", body: "abc", encoding: "br", code: http.StatusOK, handled: true}, // Decompress is off
+		{path: "/ignore", body: "abc", chunked: true, code: http.StatusNoContent, handled: true},
+		// without Content-Length the handler runs, the 413 is set when the read fails
+		{path: "/ignore", body: "too large", chunked: true, code: http.StatusRequestEntityTooLarge, handled: true},
+	}
+	for _, tt := range tests {
+		logged, handled = nil, false
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+		if tt.encoding != "" {
+			req.Header.Set("Content-Encoding", tt.encoding)
+		}
+		if tt.chunked {
+			req.ContentLength = -1
+		}
+		w := httptest.NewRecorder()
+		core.ServeHTTP(w, req)
+
+		if w.Code != tt.code || handled != tt.handled {
+			t.Errorf("%q: got %d handled %v, want %d handled %v", tt.body, w.Code, handled, tt.code, tt.handled)
+		}
+		if len(logged) != 1 || logged[0] != tt.code {
+			t.Errorf("%q: middleware saw %v, want [%d]", tt.body, logged, tt.code)
+		}
+	}
+
+	core.RequestBody.Decompress = true
+	logged = nil
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("abc"))
+	req.Header.Set("Content-Encoding", "br")
+	w := httptest.NewRecorder()
+	core.ServeHTTP(w, req)
+	if w.Code != http.StatusUnsupportedMediaType || len(logged) != 1 {
+		t.Errorf("br body: got %d, middleware saw %v, want 415 seen once", w.Code, logged)
+	}
+}
@@ -113,3 +113,19 @@ func (w *responseWriter) CloseNotify() <-chan bool {
 func (w *responseWriter) Flush() {
 	w.ResponseWriter.(http.Flusher).Flush()
 }
+
+// headWriter discards the body written by a GET handler serving a HEAD
+// request.
+type headWriter struct {
+	ResponseWriter
+}
+
+func (w *headWriter) Write(data []byte) (int, error) {
+	w.WriteHeaderNow()
+	return len(data), nil
+}
+
+func (w *headWriter) WriteString(s string) (int, error) {
+	w.WriteHeaderNow()
+	return len(s), nil
+}
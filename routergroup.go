@@ -5,6 +5,8 @@
 package klyn
 
 import (
+	"io/fs"
+	"net/http"
 	"regexp"
 )
 
@@ -25,20 +27,35 @@ type KRoutes interface {
 	PATCH(string, ...HandlerFunc) KRoutes
 	OPTIONS(string, ...HandlerFunc) KRoutes
 	HEAD(string, ...HandlerFunc) KRoutes
+
+	Static(string, string) KRoutes
+	StaticFile(string, string) KRoutes
+	StaticFS(string, http.FileSystem) KRoutes
+	StaticIOFS(string, fs.FS) KRoutes
+
+	// Name names the routes registered by the call that returned the KRoutes.
+	Name(string) KRoutes
+	// Meta attaches a metadata value to the routes registered by the call
+	// that returned the KRoutes.
+	Meta(string, interface{}) KRoutes
 }
 
 type RouterGroup struct {
 	Handlers HandlersChain
 	basePath string
 	core     *Core
+	host     *hostRouter // nil for the routes of Core, see Core.Host
 	root     bool
 }
 
 func (rg *RouterGroup) handle(method, relativePath string, handlers HandlersChain) KRoutes {
+	return rg.registered(rg.addRoute(method, relativePath, handlers))
+}
+
+func (rg *RouterGroup) addRoute(method, relativePath string, handlers HandlersChain) *RouteInfo {
 	absolutePath := rg.calculatePath(relativePath)
 	handlers = rg.combineHandlers(handlers)
-	rg.core.addRouter(method, absolutePath, handlers)
-	return rg.returnObj()
+	return rg.core.addRouter(rg.host, method, absolutePath, rg.basePath, handlers)
 }
 
 func (rg *RouterGroup) UseMiddleware(middleware ...HandlerFunc) KRoutes {
@@ -47,11 +64,27 @@ func (rg *RouterGroup) UseMiddleware(middleware ...HandlerFunc) KRoutes {
 	return rg.returnObj()
 }
 
+// NoRoute sets the handlers answering requests without a route whose path is
+// below the group's path, the group with the longest matching path wins.
+// Called on Core it handles all paths, those of host routers without
+// not-found handlers of their own too. The handlers run after the middleware
+// of the group, the response is 404 unless they set another status.
+func (rg *RouterGroup) NoRoute(handlers ...HandlerFunc) {
+	for _, nr := range rg.core.noRoutes {
+		if nr.group.basePath == rg.basePath && nr.group.host == rg.host {
+			nr.group, nr.handlers = rg, handlers
+			return
+		}
+	}
+	rg.core.noRoutes = append(rg.core.noRoutes, &noRoute{group: rg, handlers: handlers})
+}
+
 func (rg *RouterGroup) Group(relativePath string, handler ...HandlerFunc) *RouterGroup {
 	return &RouterGroup{
 		Handlers: rg.combineHandlers(handler),
 		basePath: rg.calculatePath(relativePath),
 		core:     rg.core,
+		host:     rg.host,
 	}
 }
 
@@ -93,17 +126,81 @@ func (rg *RouterGroup) HEAD(relativePath string, handlers ...HandlerFunc) KRoute
 
 // Any - register all method
 func (rg *RouterGroup) Any(relativePath string, handlers ...HandlerFunc) KRoutes {
-	rg.handle("GET", relativePath, handlers)
-	rg.handle("POST", relativePath, handlers)
-	rg.handle("PUT", relativePath, handlers)
-	rg.handle("DELETE", relativePath, handlers)
-	rg.handle("PATCH", relativePath, handlers)
-	rg.handle("OPTIONS", relativePath, handlers)
-	rg.handle("HEAD", relativePath, handlers)
-	rg.handle("CONNECT", relativePath, handlers)
-	rg.handle("TRACE", relativePath, handlers)
+	return rg.registered(
+		rg.addRoute("GET", relativePath, handlers),
+		rg.addRoute("POST", relativePath, handlers),
+		rg.addRoute("PUT", relativePath, handlers),
+		rg.addRoute("DELETE", relativePath, handlers),
+		rg.addRoute("PATCH", relativePath, handlers),
+		rg.addRoute("OPTIONS", relativePath, handlers),
+		rg.addRoute("HEAD", relativePath, handlers),
+		rg.addRoute("CONNECT", relativePath, handlers),
+		rg.addRoute("TRACE", relativePath, handlers),
+	)
+}
+
+// Name panics, only the KRoutes returned by a route registration can name
+// its routes, e.g. core.GET("/users/:id", show).Name("user.show")
+func (rg *RouterGroup) Name(name string) KRoutes {
+	panic("no route to name '" + name + "'")
+}
+
+// Meta panics, only the KRoutes returned by a route registration can attach
+// metadata to its routes.
+func (rg *RouterGroup) Meta(key string, value interface{}) KRoutes {
+	panic("no route to attach metadata '" + key + "'")
+}
+
+// registeredRoutes is the KRoutes returned by a route registration, Name
+// and Meta apply to its routes, any other method to the router.
+type registeredRoutes struct {
+	KRoutes
+	core   *Core
+	routes []*RouteInfo
+}
 
-	return rg.returnObj()
+func (rg *RouterGroup) registered(routes ...*RouteInfo) KRoutes {
+	return &registeredRoutes{KRoutes: rg.returnObj(), core: rg.core, routes: routes}
+}
+
+// Name - name the routes, e.g.
+// core.GET("/users/:id", show).Name("user.show")
+// Names are unique per host, see Core.Host.
+func (r *registeredRoutes) Name(name string) KRoutes {
+	core, host := r.core, r.routes[0].Host
+	assert1(name != "", "route name can not be empty")
+	if named, ok := core.namedRoutes[namedRouteKey(host, name)]; ok {
+		panic("route name '" + name + "' is already used by '" + named.Host + named.Path + "'")
+	}
+
+	if core.namedRoutes == nil {
+		core.namedRoutes = make(map[string]*RouteInfo)
+	}
+	for _, info := range r.routes {
+		if info.Name != "" {
+			delete(core.namedRoutes, namedRouteKey(host, info.Name))
+		}
+		info.Name = name
+	}
+	core.namedRoutes[namedRouteKey(host, name)] = r.routes[0]
+
+	return r
+}
+
+func namedRouteKey(host, name string) string {
+	return host + " " + name
+}
+
+// Meta - attach a metadata value to the routes.
+func (r *registeredRoutes) Meta(key string, value interface{}) KRoutes {
+	for _, info := range r.routes {
+		if info.Metadata == nil {
+			info.Metadata = make(K)
+		}
+		info.Metadata[key] = value
+	}
+
+	return r
 }
 
 func (rg *RouterGroup) combineHandlers(handlers HandlersChain) HandlersChain {
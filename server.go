@@ -0,0 +1,162 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const defaultShutdownTimeout = 10 * time.Second
+
+// Server returns the http.Server configuring the servers of Service and Run.
+// Configure the timeouts on it before starting, each start serves with a new
+// http.Server copying them, so the core can be started again after Shutdown.
+func (core *Core) Server() *http.Server {
+	core.serverMu.Lock()
+	defer core.serverMu.Unlock()
+
+	if core.server == nil {
+		core.server = &http.Server{}
+	}
+	return core.server
+}
+
+// OnStart registers hooks run once the listener is bound, before serving.
+func (core *Core) OnStart(hooks ...func()) {
+	core.onStart = append(core.onStart, hooks...)
+}
+
+// OnShutdown registers hooks run after the server stopped on Shutdown.
+func (core *Core) OnShutdown(hooks ...func(ctx context.Context)) {
+	core.onShutdown = append(core.onShutdown, hooks...)
+}
+
+// Service starts serving on addr and blocks until the server fails or
+// Shutdown is called, in which case nil is returned.
+func (core *Core) Service(addr ...string) (err error) {
+	return core.listenAndServe(core.newServer(resolveAddress(addr)), serveHTTP)
+}
+
+// Run is like Service but shuts the server down gracefully once ctx is done
+// or the process receives SIGINT or SIGTERM. In-flight requests are given
+// ShutdownTimeout to finish before their connections are closed.
+func (core *Core) Run(ctx context.Context, addr ...string) error {
+	srv := core.newServer(resolveAddress(addr))
+	return core.runUntilDone(ctx, func() error {
+		return core.listenAndServe(srv, serveHTTP)
+	})
+}
+
+// Shutdown stops the server from accepting connections and waits for the
+// in-flight requests until ctx is done, then closes the rest. The OnShutdown
+// hooks are run afterwards.
+func (core *Core) Shutdown(ctx context.Context) error {
+	core.serverMu.Lock()
+	srv := core.running
+	core.running = nil
+	core.serverMu.Unlock()
+
+	var err error
+	if srv != nil {
+		if err = srv.Shutdown(ctx); err != nil {
+			srv.Close()
+		}
+	}
+
+	for _, hook := range core.onShutdown {
+		hook(ctx)
+	}
+	return err
+}
+
+func serveHTTP(srv *http.Server, ln net.Listener) error {
+	return srv.Serve(ln)
+}
+
+// newServer returns a new http.Server for address configured like Server,
+// Shutdown stops it.
+func (core *Core) newServer(address string) *http.Server {
+	cfg := core.Server()
+	srv := &http.Server{
+		Addr:              address,
+		Handler:           cfg.Handler,
+		TLSConfig:         cfg.TLSConfig,
+		ReadTimeout:       cfg.ReadTimeout,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		TLSNextProto:      cfg.TLSNextProto,
+		ConnState:         cfg.ConnState,
+		ErrorLog:          cfg.ErrorLog,
+		BaseContext:       cfg.BaseContext,
+		ConnContext:       cfg.ConnContext,
+	}
+	if srv.Handler == nil {
+		srv.Handler = core
+	}
+
+	core.serverMu.Lock()
+	core.running = srv
+	core.serverMu.Unlock()
+	return srv
+}
+
+func (core *Core) listenAndServe(srv *http.Server, serve func(*http.Server, net.Listener) error) error {
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return err
+	}
+
+	srv.Addr = ln.Addr().String() // the bound address, e.g. of port 0
+	log.Println("start service on:", ln.Addr())
+	for _, hook := range core.onStart {
+		hook()
+	}
+
+	if err = serve(srv, ln); err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+func (core *Core) runUntilDone(ctx context.Context, start func() error) error {
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- start()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	// restore the default behavior, a second signal kills the process
+	stop()
+	log.Println("shutting down service")
+
+	shutdownCtx := context.Background()
+	if core.ShutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		shutdownCtx, cancel = context.WithTimeout(shutdownCtx, core.ShutdownTimeout)
+		defer cancel()
+	}
+
+	err := core.Shutdown(shutdownCtx)
+	if startErr := <-errCh; err == nil {
+		err = startErr
+	}
+	return err
+}
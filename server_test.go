@@ -0,0 +1,144 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// startTestServer runs start in the background and returns the address the
+// server listens on and the channel of the result of start.
+func startTestServer(t *testing.T, core *Core, start func() error) (string, <-chan error) {
+	t.Helper()
+	started := make(chan string, 1)
+	core.onStart = nil
+	core.OnStart(func() {
+		core.serverMu.Lock()
+		started <- core.running.Addr
+		core.serverMu.Unlock()
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- start() }()
+
+	select {
+	case addr := <-started:
+		return addr, done
+	case err := <-done:
+		t.Fatalf("server did not start: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not start")
+	}
+	return "", nil
+}
+
+func getBody(t *testing.T, url string) (string, error) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func waitServer(t *testing.T, done <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not stop")
+		return nil
+	}
+}
+
+func TestRunShutdown(t *testing.T) {
+	core := New()
+	core.GET("/", func(c *Context) { c.String(http.StatusOK, "ok") })
+	shutdowns := 0
+	core.OnShutdown(func(ctx context.Context) { shutdowns++ })
+
+	// the core serves again after each shutdown
+	for i := 1; i <= 2; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		addr, done := startTestServer(t, core, func() error { return core.Run(ctx, "127.0.0.1:0") })
+
+		if body, err := getBody(t, "http://"+addr+"/"); err != nil || body != "ok" {
+			t.Fatalf("run %d: got %q %v, want ok", i, body, err)
+		}
+		cancel()
+		if err := waitServer(t, done); err != nil {
+			t.Errorf("run %d: Run returned %v", i, err)
+		}
+		if shutdowns != i {
+			t.Errorf("run %d: OnShutdown ran %d times", i, shutdowns)
+		}
+	}
+
+	addr, done := startTestServer(t, core, func() error { return core.Service("127.0.0.1:0") })
+	if body, err := getBody(t, "http://"+addr+"/"); err != nil || body != "ok" {
+		t.Fatalf("Service after Run: got %q %v, want ok", body, err)
+	}
+	if err := core.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitServer(t, done); err != nil {
+		t.Errorf("Service returned %v", err)
+	}
+}
+
+func TestRunDrainsRequests(t *testing.T) {
+	core := New()
+	entered, release := make(chan bool), make(chan bool)
+	core.GET("/slow", func(c *Context) {
+		entered <- true
+		<-release
+		c.String(http.StatusOK, "done")
+	})
+
+	tests := []struct {
+		timeout   time.Duration
+		releaseIn time.Duration
+		served    bool
+	}{
+		{timeout: 5 * time.Second, releaseIn: 50 * time.Millisecond, served: true},
+		{timeout: 50 * time.Millisecond, releaseIn: time.Second, served: false},
+	}
+	for _, tt := range tests {
+		core.ShutdownTimeout = tt.timeout
+		ctx, cancel := context.WithCancel(context.Background())
+		addr, done := startTestServer(t, core, func() error { return core.Run(ctx, "127.0.0.1:0") })
+
+		result := make(chan error, 1)
+		go func() {
+			body, err := getBody(t, "http://"+addr+"/slow")
+			if err == nil && body != "done" {
+				err = errors.New("unexpected body " + body)
+			}
+			result <- err
+		}()
+		<-entered
+		cancel()
+		time.AfterFunc(tt.releaseIn, func() { release <- true })
+
+		runErr := waitServer(t, done)
+		if err := <-result; (err == nil) != tt.served {
+			t.Errorf("timeout %v: request got %v, want served %v", tt.timeout, err, tt.served)
+		}
+		if tt.served && runErr != nil {
+			t.Errorf("timeout %v: Run returned %v", tt.timeout, runErr)
+		}
+		if !tt.served && !errors.Is(runErr, context.DeadlineExceeded) {
+			t.Errorf("timeout %v: Run returned %v, want deadline exceeded", tt.timeout, runErr)
+		}
+	}
+}
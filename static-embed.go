@@ -0,0 +1,276 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	immutableCacheControl  = "public, max-age=31536000, immutable"
+	revalidateCacheControl = "no-cache"
+)
+
+// precompressed encodings looked up as sibling files, in order of preference.
+var precompressed = []struct {
+	encoding, ext string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+type embeddedFile struct {
+	name        string
+	etag        string
+	contentType string
+	immutable   bool
+	variants    map[string]embeddedVariant // keyed by content encoding
+}
+
+type embeddedVariant struct {
+	name string
+	etag string
+}
+
+// EmbedConfig configures RouterGroup.StaticEmbedWithConfig.
+type EmbedConfig struct {
+	// FS is the served file system, usually an embed.FS.
+	FS fs.FS
+	// Fingerprinted reports whether the file name carries a content hash,
+	// such files are cached as immutable. By default the name must end with
+	// a hex hash, e.g. "app.3f9a2c1e.js", or an 8 character base64url hash
+	// as written by Vite and esbuild, e.g. "index-B1x_9aQz.css".
+	Fingerprinted func(name string) bool
+}
+
+// StaticEmbed serves fsys, usually an embed.FS, under relativePath. Use
+// fs.Sub to serve a sub directory of it.
+//
+// All files are hashed once on registration to build strong ETags. If a
+// file has a ".br" or ".gz" sibling and the client accepts that encoding,
+// the sibling is served instead. Fingerprinted files, e.g. "app.3f9a2c1e.js",
+// are cached for a year as immutable, other files must be revalidated.
+func (rg *RouterGroup) StaticEmbed(relativePath string, fsys fs.FS) KRoutes {
+	return rg.StaticEmbedWithConfig(relativePath, EmbedConfig{FS: fsys})
+}
+
+// StaticEmbedWithConfig serves cfg.FS under relativePath like StaticEmbed.
+func (rg *RouterGroup) StaticEmbedWithConfig(relativePath string, cfg EmbedConfig) KRoutes {
+	assert1(cfg.FS != nil, "static file system can not be nil")
+	if strings.ContainsAny(relativePath, ":*") {
+		panic("URL parameters can not be used when serving a static folder")
+	}
+	if cfg.Fingerprinted == nil {
+		cfg.Fingerprinted = isFingerprinted
+	}
+
+	files, err := indexEmbeddedFiles(cfg.FS, cfg.Fingerprinted)
+	if err != nil {
+		panic("index embedded files failed: " + err.Error())
+	}
+
+	handler := func(c *Context) {
+		serveEmbedded(c, cfg.FS, files, c.Params.ByName("filepath"))
+	}
+	urlPattern := path.Join(relativePath, "/*filepath")
+
+	return rg.registered(
+		rg.addRoute(http.MethodGet, urlPattern, HandlersChain{handler}),
+		rg.addRoute(http.MethodHead, urlPattern, HandlersChain{handler}),
+	)
+}
+
+func indexEmbeddedFiles(fsys fs.FS, fingerprinted func(string) bool) (map[string]*embeddedFile, error) {
+	files := make(map[string]*embeddedFile)
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || isPrecompressedVariant(fsys, name) {
+			return err
+		}
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		f := &embeddedFile{
+			name:        name,
+			etag:        strongETag(data),
+			contentType: mime.TypeByExtension(path.Ext(name)),
+			immutable:   fingerprinted(name),
+		}
+		if f.contentType == "" {
+			f.contentType = http.DetectContentType(data)
+		}
+
+		for _, pc := range precompressed {
+			data, err := fs.ReadFile(fsys, name+pc.ext)
+			if err != nil {
+				continue
+			}
+			if f.variants == nil {
+				f.variants = make(map[string]embeddedVariant)
+			}
+			f.variants[pc.encoding] = embeddedVariant{name: name + pc.ext, etag: strongETag(data)}
+		}
+
+		files[name] = f
+		return nil
+	})
+
+	return files, err
+}
+
+func serveEmbedded(c *Context, fsys fs.FS, files map[string]*embeddedFile, name string) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+
+	f, ok := files[name]
+	if !ok {
+		// a directory, serve its index
+		f, ok = files[path.Join(name, indexPage)]
+		if ok && name != "" && !strings.HasSuffix(c.Request.URL.Path, "/") {
+			localRedirect(c, path.Base(c.Request.URL.Path)+"/")
+			return
+		}
+	}
+	if !ok {
+		notFound(c)
+		return
+	}
+
+	header := c.Writer.Header()
+	fileName, etag := f.name, f.etag
+	if len(f.variants) > 0 {
+		addVary(header, "Accept-Encoding")
+		if encoding := acceptedEncoding(c.GetHeader("Accept-Encoding"), f.variants); encoding != "" {
+			header.Set("Content-Encoding", encoding)
+			fileName, etag = f.variants[encoding].name, f.variants[encoding].etag
+		}
+	}
+
+	header.Set("Content-Type", f.contentType)
+	header.Set("ETag", etag)
+	if f.immutable {
+		header.Set("Cache-Control", immutableCacheControl)
+	} else {
+		header.Set("Cache-Control", revalidateCacheControl)
+	}
+
+	file, err := fsys.Open(fileName)
+	if err != nil {
+		header.Del("Content-Encoding")
+		staticError(c, err)
+		return
+	}
+	defer file.Close()
+
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		content = bytes.NewReader(data)
+	}
+
+	http.ServeContent(c.Writer, c.Request, f.name, time.Time{}, content)
+}
+
+// acceptedEncoding returns the preferred precompressed encoding accepted by
+// the Accept-Encoding header, or "" for the identity encoding.
+func acceptedEncoding(header string, variants map[string]embeddedVariant) string {
+	offered := make([]string, 0, len(variants))
+	for _, pc := range precompressed {
+		if _, ok := variants[pc.encoding]; ok {
+			offered = append(offered, pc.encoding)
+		}
+	}
+	return negotiateEncoding(header, offered)
+}
+
+// isPrecompressedVariant reports whether name is the ".br" or ".gz" sibling
+// of a file, archives without uncompressed file are served as they are.
+func isPrecompressedVariant(fsys fs.FS, name string) bool {
+	for _, pc := range precompressed {
+		if !strings.HasSuffix(name, pc.ext) {
+			continue
+		}
+		if fi, err := fs.Stat(fsys, strings.TrimSuffix(name, pc.ext)); err == nil && !fi.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+func strongETag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+}
+
+// isFingerprinted reports whether the part of the file name before the
+// extension ends with a content hash: a '.' or '-' separated hex segment of
+// at least 8 characters with letters and digits, e.g. "app.3f9a2c1e.js", or
+// 8 base64url characters with digits and both letter cases, e.g.
+// "index-B1x_9aQz.css". Words and versions like "jquery.min" or
+// "app-20240101" are not fingerprints.
+func isFingerprinted(name string) bool {
+	base := path.Base(name)
+	base = strings.TrimSuffix(base, path.Ext(base))
+
+	if i := strings.LastIndexAny(base, ".-"); i >= 0 && isHexHash(base[i+1:]) {
+		return true
+	}
+	// a base64url hash may contain '-' itself
+	n := len(base) - 8
+	return n > 0 && (base[n-1] == '.' || base[n-1] == '-') && isBase64Hash(base[n:])
+}
+
+func isHexHash(s string) bool {
+	if len(s) < 8 {
+		return false
+	}
+
+	var digit, lower, upper bool
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digit = true
+		case r >= 'a' && r <= 'f':
+			lower = true
+		case r >= 'A' && r <= 'F':
+			upper = true
+		default:
+			return false
+		}
+	}
+	return digit && lower != upper
+}
+
+func isBase64Hash(s string) bool {
+	var digit, lower, upper bool
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			digit = true
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r == '-' || r == '_':
+		default:
+			return false
+		}
+	}
+	return digit && lower && upper
+}
@@ -0,0 +1,199 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"fmt"
+	"html"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const indexPage = "index.html"
+
+// StaticConfig configures RouterGroup.StaticWithConfig.
+type StaticConfig struct {
+	// FS is the served file system, use http.Dir for a directory on disk and
+	// http.FS for an fs.FS.
+	FS http.FileSystem
+	// Browse lists the files of directories without index.html.
+	Browse bool
+	// SPA serves /index.html for missing paths whose last segment has no
+	// extension, so the routing of single page applications works on reload.
+	SPA bool
+	// MaxAge sets "Cache-Control: public, max-age" if greater than zero.
+	MaxAge time.Duration
+}
+
+// Static serves the files of the root directory under relativePath, e.g.
+// core.Static("/assets", "./public") serves ./public/app.js as /assets/app.js.
+func (rg *RouterGroup) Static(relativePath, root string) KRoutes {
+	return rg.StaticWithConfig(relativePath, StaticConfig{FS: http.Dir(root)})
+}
+
+// StaticFS serves the file system under relativePath.
+func (rg *RouterGroup) StaticFS(relativePath string, fs http.FileSystem) KRoutes {
+	return rg.StaticWithConfig(relativePath, StaticConfig{FS: fs})
+}
+
+// StaticIOFS serves fsys under relativePath like StaticFS, e.g. an embed.FS
+// or os.DirFS. See StaticEmbed for precompressed and fingerprinted files.
+func (rg *RouterGroup) StaticIOFS(relativePath string, fsys fs.FS) KRoutes {
+	assert1(fsys != nil, "static file system can not be nil")
+	return rg.StaticWithConfig(relativePath, StaticConfig{FS: http.FS(fsys)})
+}
+
+// StaticWithConfig serves cfg.FS under relativePath. Files are served with
+// ETag and Last-Modified headers and support conditional and Range requests.
+func (rg *RouterGroup) StaticWithConfig(relativePath string, cfg StaticConfig) KRoutes {
+	assert1(cfg.FS != nil, "static file system can not be nil")
+	if strings.ContainsAny(relativePath, ":*") {
+		panic("URL parameters can not be used when serving a static folder")
+	}
+
+	handler := func(c *Context) {
+		serveStatic(c, cfg, c.Params.ByName("filepath"))
+	}
+	urlPattern := path.Join(relativePath, "/*filepath")
+
+	return rg.registered(
+		rg.addRoute(http.MethodGet, urlPattern, HandlersChain{handler}),
+		rg.addRoute(http.MethodHead, urlPattern, HandlersChain{handler}),
+	)
+}
+
+// StaticFile serves a single file under relativePath.
+func (rg *RouterGroup) StaticFile(relativePath, file string) KRoutes {
+	if strings.ContainsAny(relativePath, ":*") {
+		panic("URL parameters can not be used when serving a static file")
+	}
+
+	cfg := StaticConfig{FS: http.Dir(filepath.Dir(file))}
+	name := "/" + filepath.Base(file)
+	handler := func(c *Context) {
+		serveStatic(c, cfg, name)
+	}
+
+	return rg.registered(
+		rg.addRoute(http.MethodGet, relativePath, HandlersChain{handler}),
+		rg.addRoute(http.MethodHead, relativePath, HandlersChain{handler}),
+	)
+}
+
+func serveStatic(c *Context, cfg StaticConfig, name string) {
+	// path.Clean of a rooted path removes any "..", the request can not
+	// escape the file system
+	name = path.Clean("/" + name)
+
+	f, err := cfg.FS.Open(name)
+	if err != nil {
+		if cfg.SPA && os.IsNotExist(err) && !strings.Contains(path.Base(name), ".") {
+			f, err = cfg.FS.Open("/" + indexPage)
+			name = "/" + indexPage
+		}
+		if err != nil {
+			staticError(c, err)
+			return
+		}
+	}
+	defer f.Close()
+
+	d, err := f.Stat()
+	if err != nil {
+		staticError(c, err)
+		return
+	}
+
+	if d.IsDir() {
+		// redirect to the canonical directory path, so relative links work
+		if p := c.Request.URL.Path; !strings.HasSuffix(p, "/") {
+			localRedirect(c, path.Base(p)+"/")
+			return
+		}
+
+		index, err := cfg.FS.Open(path.Join(name, indexPage))
+		if err == nil {
+			defer index.Close()
+			if id, err := index.Stat(); err == nil && !id.IsDir() {
+				f, d = index, id
+			}
+		}
+	}
+
+	if d.IsDir() {
+		if !cfg.Browse {
+			notFound(c)
+			return
+		}
+		dirList(c, f)
+		return
+	}
+
+	header := c.Writer.Header()
+	if header.Get("ETag") == "" {
+		header.Set("ETag", fileETag(d.Size(), d.ModTime()))
+	}
+	if cfg.MaxAge > 0 {
+		header.Set("Cache-Control", "public, max-age="+strconv.Itoa(int(cfg.MaxAge/time.Second)))
+	}
+	http.ServeContent(c.Writer, c.Request, d.Name(), d.ModTime(), f)
+}
+
+// fileETag is a weak validator of the file version.
+func fileETag(size int64, modTime time.Time) string {
+	return `W/"` + strconv.FormatInt(size, 16) + "-" + strconv.FormatInt(modTime.UnixNano(), 16) + `"`
+}
+
+func staticError(c *Context, err error) {
+	switch {
+	case os.IsNotExist(err):
+		notFound(c)
+	case os.IsPermission(err):
+		c.AbortWithStatus(http.StatusForbidden)
+	default:
+		c.AbortWithStatus(http.StatusInternalServerError)
+	}
+}
+
+func localRedirect(c *Context, newPath string) {
+	if q := c.Request.URL.RawQuery; q != "" {
+		newPath += "?" + q
+	}
+	c.Writer.Header().Set("Location", newPath)
+	c.AbortWithStatus(http.StatusMovedPermanently)
+}
+
+func dirList(c *Context, f http.File) {
+	dirs, err := f.Readdir(-1)
+	if err != nil {
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].Name() < dirs[j].Name() })
+
+	c.Writer.Header().Set("Content-Type", htmlContent)
+	c.Status(http.StatusOK)
+	fmt.Fprintf(c.Writer, "<pre>\n")
+	for _, d := range dirs {
+		name := d.Name()
+		if d.IsDir() {
+			name += "/"
+		}
+		// name may contain '?' or '#', which must be escaped to remain
+		// part of the URL path, and not indicate the start of a query
+		// string or fragment.
+		u := url.URL{Path: name}
+		fmt.Fprintf(c.Writer, "<a href=\"%s\">%s</a>\n", u.String(), html.EscapeString(name))
+	}
+	fmt.Fprintf(c.Writer, "</pre>\n")
+}
@@ -0,0 +1,93 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStaticIOFS(t *testing.T) {
+	core := New()
+	core.StaticIOFS("/assets", fstest.MapFS{
+		"app.js":          {Data: []byte("console.log(1)")},
+		"docs/index.html": {Data: []byte("<h1>docs</h1>")},
+	})
+
+	tests := []struct {
+		path, body string
+		code       int
+	}{
+		{"/assets/app.js", "console.log(1)", http.StatusOK},
+		{"/assets/docs/", "<h1>docs</h1>", http.StatusOK},
+		{"/assets/missing.js", "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		core.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if w.Code != tt.code || (tt.body != "" && w.Body.String() != tt.body) {
+			t.Errorf("%s: got %d %q, want %d %q", tt.path, w.Code, w.Body.String(), tt.code, tt.body)
+		}
+	}
+}
+
+func TestIsFingerprinted(t *testing.T) {
+	tests := map[string]bool{
+		"app.3f9a2c1e.js":        true,
+		"assets/app-3F9A2C1E.js": true,
+		"index-B1x_9aQz.css":     true,
+		"index-B1x-9aQz.css":     true,
+		"app.js":                 false,
+		"jquery.min.js":          false,
+		"app-20240101.js":        false,
+		"app.deadbeef.js":        false,
+		"chapter-12345678.html":  false,
+		"docs-overview.html":     false,
+		"book-chapters.html":     false,
+	}
+	for name, want := range tests {
+		if got := isFingerprinted(name); got != want {
+			t.Errorf("isFingerprinted(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestStaticEmbed(t *testing.T) {
+	core := New()
+	core.StaticEmbed("/", fstest.MapFS{
+		"app.3f9a2c1e.js":    {Data: []byte("plain")},
+		"app.3f9a2c1e.js.gz": {Data: []byte("gzipped")},
+		"backup.tar.gz":      {Data: []byte("archive")},
+	})
+	core.StaticEmbedWithConfig("/v", EmbedConfig{
+		FS:            fstest.MapFS{"app.js": {Data: []byte("v")}},
+		Fingerprinted: func(name string) bool { return true },
+	})
+
+	tests := []struct {
+		path, encoding, body, cacheControl string
+	}{
+		{"/app.3f9a2c1e.js", "", "plain", immutableCacheControl},
+		{"/app.3f9a2c1e.js", "gzip", "gzipped", immutableCacheControl},
+		{"/backup.tar.gz", "", "archive", revalidateCacheControl},
+		{"/v/app.js", "", "v", immutableCacheControl},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.encoding != "" {
+			req.Header.Set("Accept-Encoding", tt.encoding)
+		}
+		w := httptest.NewRecorder()
+		core.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || w.Body.String() != tt.body {
+			t.Errorf("%s %s: got %d %q, want 200 %q", tt.path, tt.encoding, w.Code, w.Body.String(), tt.body)
+		}
+		if cc := w.Header().Get("Cache-Control"); cc != tt.cacheControl {
+			t.Errorf("%s: Cache-Control %q, want %q", tt.path, cc, tt.cacheControl)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// certCheckInterval is how often the certificate files are checked for changes.
+const certCheckInterval = time.Second
+
+// ServiceTLS is like Service but serves HTTPS with the certificate and key
+// files, which are reloaded when they change on disk.
+func (core *Core) ServiceTLS(addr, certFile, keyFile string) error {
+	cfg, err := TLSConfig(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+
+	return core.ServiceTLSConfig(addr, cfg)
+}
+
+// ServiceTLSConfig is like Service but serves HTTPS with cfg, which must
+// provide Certificates or GetCertificate.
+func (core *Core) ServiceTLSConfig(addr string, cfg *tls.Config) error {
+	return core.listenAndServe(core.newServer(addr), serveTLS(cfg))
+}
+
+// RunTLS is like Run but serves HTTPS with cfg.
+func (core *Core) RunTLS(ctx context.Context, addr string, cfg *tls.Config) error {
+	srv := core.newServer(addr)
+	return core.runUntilDone(ctx, func() error {
+		return core.listenAndServe(srv, serveTLS(cfg))
+	})
+}
+
+func serveTLS(cfg *tls.Config) func(*http.Server, net.Listener) error {
+	return func(srv *http.Server, ln net.Listener) error {
+		srv.TLSConfig = cfg.Clone()
+		return srv.ServeTLS(ln, "", "")
+	}
+}
+
+// TLSConfig returns a config serving the certificate and key files, which
+// are reloaded when they change on disk.
+func TLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	r, err := NewCertReloader(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: r.GetCertificate,
+	}, nil
+}
+
+// MutualTLSConfig is like TLSConfig but also requires clients to present a
+// certificate signed by one of the CAs in the PEM file clientCAFile.
+// The verified certificate is available by Context.PeerCertificate.
+func MutualTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cfg, err := TLSConfig(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pem, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("klyn: no certificate found in " + clientCAFile)
+	}
+
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	return cfg, nil
+}
+
+// CertReloader keeps a certificate loaded from disk and reloads it once the
+// files change, so certificates can be rotated without a restart.
+// If a reload fails the previous certificate keeps being served.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+// NewCertReloader loads the certificate and key files.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	modTime, err := r.filesModTime()
+	if err != nil {
+		return nil, err
+	}
+	if err = r.load(modTime); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate can be used as tls.Config.GetCertificate.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	cert, checked := r.cert, r.checked
+	r.mu.RUnlock()
+
+	if time.Since(checked) < certCheckInterval {
+		return cert, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if time.Since(r.checked) < certCheckInterval {
+		return r.cert, nil
+	}
+	r.checked = time.Now()
+
+	modTime, err := r.filesModTime()
+	if err == nil && !modTime.Equal(r.modTime) {
+		err = r.load(modTime)
+	}
+	if err != nil {
+		log.Printf("[WARNING] reload certificate %s failed, keep serving the previous one: %v\n", r.certFile, err)
+	}
+	return r.cert, nil
+}
+
+// load must be called with r.mu held or before r is shared.
+func (r *CertReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	r.cert = &cert
+	r.modTime = modTime
+	r.checked = time.Now()
+	return nil
+}
+
+// filesModTime returns the latest modification time of the two files.
+func (r *CertReloader) filesModTime() (time.Time, error) {
+	certInfo, err := os.Stat(r.certFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+	keyInfo, err := os.Stat(r.keyFile)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if keyInfo.ModTime().After(certInfo.ModTime()) {
+		return keyInfo.ModTime(), nil
+	}
+	return certInfo.ModTime(), nil
+}
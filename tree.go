@@ -5,9 +5,10 @@
 package klyn
 
 import (
+	"fmt"
 	"net/url"
 	"strings"
-	"unicode"
+	"unicode/utf8"
 )
 
 // Param is a single URL parameter, consisting of a key and a value.
@@ -55,6 +56,20 @@ func (trees methodTrees) get(method string) *node {
 	return nil
 }
 
+// RouteConflictError is the panic value of registering a route which
+// collides with an already registered one.
+type RouteConflictError struct {
+	Method   string
+	Path     string // the route being registered
+	Existing string // the registered route it collides with
+	Reason   string
+}
+
+func (e *RouteConflictError) Error() string {
+	return fmt.Sprintf("klyn: route %s '%s' conflicts with existing route '%s': %s",
+		e.Method, e.Path, e.Existing, e.Reason)
+}
+
 func min(a, b int) int {
 	if a <= b {
 		return a
@@ -62,38 +77,148 @@ func min(a, b int) int {
 	return b
 }
 
-func countParams(path string) uint8 {
-	var n uint
+type nodeType uint8
+
+const (
+	static nodeType = iota // default
+	param
+	catchAll
+)
+
+// pathToken is a static text or a wildcard of a route path.
+type pathToken struct {
+	kind       nodeType
+	text       string // static text, or the wildcard as written without '?'
+	name       string // param name of a wildcard
+	constraint paramConstraint
+	optional   bool
+}
+
+// parsePath splits the route path into its static texts and wildcards.
+// A param name consists of letters, digits and '_', so static text may
+// follow a param within its segment, e.g. "/files/:name.:ext". A param may
+// have a constraint, e.g. ":id<int>", and the last param of the path may be
+// optional, e.g. "/users/:id?". A catch-all must follow the last '/' of the
+// path, its token includes that '/'.
+func parsePath(path string) ([]pathToken, error) {
+	var tokens []pathToken
+	start := 0 // start of the current static text
 	for i := 0; i < len(path); i++ {
-		if path[i] != ':' && path[i] != '*' {
+		c := path[i]
+		if c != ':' && c != '*' {
 			continue
 		}
-		n++
+
+		end := i + 1
+		for end < len(path) && isParamNameByte(path[end]) {
+			end++
+		}
+		tok := pathToken{kind: param, name: path[i+1 : end]}
+		if tok.name == "" {
+			return nil, fmt.Errorf("klyn: wildcards must be named with a non-empty name in path '%s'", path)
+		}
+
+		if end < len(path) && path[end] == '<' {
+			j := constraintEnd(path, end)
+			if j < 0 {
+				return nil, fmt.Errorf("klyn: unterminated constraint of wildcard '%s' in path '%s'", path[i:end], path)
+			}
+			expr := path[end+1 : j]
+			var err error
+			if tok.constraint, err = compileConstraint(expr); err != nil {
+				return nil, fmt.Errorf("klyn: invalid constraint '%s' of wildcard '%s' in path '%s': %v",
+					expr, path[i:end], path, err)
+			}
+			end = j + 1
+		}
+		tok.text = path[i:end]
+
+		text := path[start:i]
+		if c == '*' {
+			if end != len(path) {
+				return nil, fmt.Errorf("klyn: catch-all routes are only allowed at the end of the path in path '%s'", path)
+			}
+			if !strings.HasSuffix(text, "/") {
+				return nil, fmt.Errorf("klyn: no / before catch-all in path '%s'", path)
+			}
+			text = text[:len(text)-1]
+			tok.kind = catchAll
+		} else if end < len(path) && path[end] == '?' {
+			if end+1 != len(path) || !strings.HasSuffix(text, "/") {
+				return nil, fmt.Errorf("klyn: optional param '%s' must be the last segment of the path in path '%s'",
+					tok.text, path)
+			}
+			tok.optional = true
+			end++
+		}
+
+		if text != "" {
+			tokens = append(tokens, pathToken{kind: static, text: text})
+		} else if c == ':' && len(tokens) > 0 && tokens[len(tokens)-1].kind != static {
+			return nil, fmt.Errorf("klyn: wildcards '%s' and '%s' must be separated by static text in path '%s'",
+				tokens[len(tokens)-1].text, tok.text, path)
+		}
+		tokens = append(tokens, tok)
+		start = end
+		i = end - 1
 	}
-	if n >= 255 {
-		return 255
+	if start < len(path) {
+		tokens = append(tokens, pathToken{kind: static, text: path[start:]})
 	}
-	return uint8(n)
+	return tokens, nil
 }
 
-type nodeType uint8
+func isParamNameByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
 
-const (
-	static nodeType = iota // default
-	root
-	param
-	catchAll
-)
+// constraintEnd returns the index of the '>' closing the constraint starting
+// at path[i], or -1. Nested '<' '>' pairs, e.g. of a named regexp group, are
+// part of the constraint.
+func constraintEnd(path string, i int) int {
+	depth := 0
+	for ; i < len(path); i++ {
+		switch path[i] {
+		case '<':
+			depth++
+		case '>':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// paramNames returns the names of the wildcards of the route path.
+func paramNames(path string) (names []string) {
+	tokens, _ := parsePath(path)
+	for _, tok := range tokens {
+		if tok.kind != static {
+			names = append(names, tok.name)
+		}
+	}
+	return
+}
 
+// node is a node of the radix tree of the routes of a method. A static node
+// matches its path, a param node a non-empty value up to the next '/' or up
+// to static text of its children within the segment, a catchAll node the
+// rest of the path. Below a node the static children are tried first, then
+// the params and the catch-all last, so "/users/new" wins over
+// "/users/:id" which is still matched by "/users/newest".
 type node struct {
-	path      string
-	indices   string
-	children  []*node
-	handlers  HandlersChain
-	priority  uint32
-	nType     nodeType
-	maxParams uint8
-	wildChild bool
+	path       string  // static text, or the wildcard, e.g. ":id<int>"
+	indices    string  // first bytes of the static children
+	children   []*node // static children
+	params     []*node // param children, the constrained ones first
+	catchAll   *node
+	handlers   HandlersChain
+	fullPath   string // the route of the handlers, or the route which added the node
+	priority   uint32
+	nType      nodeType
+	key        string          // param name of a param or catchAll node
+	constraint paramConstraint // optional constraint of the param value
 }
 
 // increments priority of the given child and reorders if necessary.
@@ -120,383 +245,272 @@ func (n *node) incrementChildPrio(pos int) int {
 	return newPos
 }
 
-// addRoute adds a node with the given handle to the path.
+// addRoute adds a node with the given handle to the path. A route with an
+// optional param is added with and without it.
 // Not concurrency-safe!
-func (n *node) addRoute(path string, handlers HandlersChain) {
-	fullPath := path
-	n.priority++
-	numParams := countParams(path)
-
-	// non-empty tree
-	if len(n.path) > 0 || len(n.children) > 0 {
-	walk:
-		for {
-			// Update maxParams of the current node
-			if numParams > n.maxParams {
-				n.maxParams = numParams
-			}
-
-			// Find the longest common prefix.
-			// This also implies that the common prefix contains no ':' or '*'
-			// since the existing key can't contain those chars.
-			i := 0
-			max := min(len(path), len(n.path))
-			for i < max && path[i] == n.path[i] {
-				i++
-			}
-
-			// Split edge
-			if i < len(n.path) {
-				child := node{
-					path:      n.path[i:],
-					wildChild: n.wildChild,
-					indices:   n.indices,
-					children:  n.children,
-					handlers:  n.handlers,
-					priority:  n.priority - 1,
-				}
-
-				// Update maxParams (max of all children)
-				for i := range child.children {
-					if child.children[i].maxParams > child.maxParams {
-						child.maxParams = child.children[i].maxParams
-					}
-				}
-
-				n.children = []*node{&child}
-				// []byte for proper unicode char conversion, see #65
-				n.indices = string([]byte{n.path[i]})
-				n.path = path[:i]
-				n.handlers = nil
-				n.wildChild = false
-			}
-
-			// Make new node a child of this node
-			if i < len(path) {
-				path = path[i:]
-
-				if n.wildChild {
-					n = n.children[0]
-					n.priority++
-
-					// Update maxParams of the child node
-					if numParams > n.maxParams {
-						n.maxParams = numParams
-					}
-					numParams--
-
-					// Check if the wildcard matches
-					if len(path) >= len(n.path) && n.path == path[:len(n.path)] {
-						// check for longer wildcard, e.g. :name and :names
-						if len(n.path) >= len(path) || path[len(n.path)] == '/' {
-							continue walk
-						}
-					}
-
-					panic("path segment '" + path +
-						"' conflicts with existing wildcard '" + n.path +
-						"' in path '" + fullPath + "'")
-				}
-
-				c := path[0]
-
-				// slash after param
-				if n.nType == param && c == '/' && len(n.children) == 1 {
-					n = n.children[0]
-					n.priority++
-					continue walk
-				}
-
-				// Check if a child with the next path byte exists
-				for i := 0; i < len(n.indices); i++ {
-					if c == n.indices[i] {
-						i = n.incrementChildPrio(i)
-						n = n.children[i]
-						continue walk
-					}
-				}
-
-				// Otherwise insert it
-				if c != ':' && c != '*' {
-					// []byte for proper unicode char conversion, see #65
-					n.indices += string([]byte{c})
-					child := &node{
-						maxParams: numParams,
-					}
-					n.children = append(n.children, child)
-					n.incrementChildPrio(len(n.indices) - 1)
-					n = child
-				}
-				n.insertChild(numParams, path, fullPath, handlers)
-				return
+func (n *node) addRoute(path string, handlers HandlersChain) error {
+	tokens, err := parsePath(path)
+	if err != nil {
+		return err
+	}
 
-			} else if i == len(path) { // Make node a (in-path) leaf
-				if n.handlers != nil {
-					panic("handlers are already registered for path ''" + fullPath + "'")
-				}
-				n.handlers = handlers
+	if last := len(tokens) - 1; last >= 0 && tokens[last].optional {
+		// the path without the param and its '/', e.g. "/users" of "/users/:id?"
+		base := append([]pathToken(nil), tokens[:last]...)
+		prev := &base[len(base)-1]
+		if prev.text = strings.TrimSuffix(prev.text, "/"); prev.text == "" {
+			if len(base) == 1 {
+				prev.text = "/"
+			} else {
+				base = base[:len(base)-1]
 			}
-			return
 		}
-	} else { // Empty tree
-		n.insertChild(numParams, path, fullPath, handlers)
-		n.nType = root
+		if err := n.insert(base, path, handlers); err != nil {
+			return err
+		}
 	}
+	return n.insert(tokens, path, handlers)
 }
 
-func (n *node) insertChild(numParams uint8, path string, fullPath string, handlers HandlersChain) {
-	var offset int // already handled bytes of the path
-
-	// find prefix until first wildcard (beginning with ':'' or '*'')
-	for i, max := 0, len(path); numParams > 0; i++ {
-		c := path[i]
-		if c != ':' && c != '*' {
-			continue
-		}
-
-		// find wildcard end (either '/' or path end)
-		end := i + 1
-		for end < max && path[end] != '/' {
-			switch path[end] {
-			// the wildcard name must not contain ':' and '*'
-			case ':', '*':
-				panic("only one wildcard per path segment is allowed, has: '" +
-					path[i:] + "' in path '" + fullPath + "'")
-			default:
-				end++
-			}
+func (n *node) insert(tokens []pathToken, fullPath string, handlers HandlersChain) error {
+	n.priority++
+	for _, tok := range tokens {
+		var err error
+		switch tok.kind {
+		case static:
+			n = n.insertStatic(tok.text, fullPath)
+		case param:
+			n, err = n.insertParam(tok, fullPath)
+		case catchAll:
+			n, err = n.insertCatchAll(tok, fullPath)
 		}
-
-		// check if this Node existing children which would be
-		// unreachable if we insert the wildcard here
-		if len(n.children) > 0 {
-			panic("wildcard route '" + path[i:end] +
-				"' conflicts with existing children in path '" + fullPath + "'")
+		if err != nil {
+			return err
 		}
+	}
 
-		// check if the wildcard has a name
-		if end-i < 2 {
-			panic("wildcards must be named with a non-empty name in path '" + fullPath + "'")
-		}
+	if n.handlers != nil {
+		return &RouteConflictError{Path: fullPath, Existing: n.fullPath, Reason: "handlers are already registered"}
+	}
+	n.handlers = handlers
+	n.fullPath = fullPath
+	return nil
+}
 
-		if c == ':' { // param
-			// split path at the beginning of the wildcard
-			if i > 0 {
-				n.path = path[offset:i]
-				offset = i
+// insertStatic returns the static node for path below n, splitting the edge
+// of a child sharing a prefix with path.
+func (n *node) insertStatic(path, fullPath string) *node {
+walk:
+	for {
+		for i := 0; i < len(n.indices); i++ {
+			if n.indices[i] != path[0] {
+				continue
 			}
+			i = n.incrementChildPrio(i)
+			child := n.children[i]
 
-			child := &node{
-				nType:     param,
-				maxParams: numParams,
+			// Find the longest common prefix.
+			j := 0
+			max := min(len(path), len(child.path))
+			for j < max && path[j] == child.path[j] {
+				j++
 			}
-			n.children = []*node{child}
-			n.wildChild = true
-			n = child
-			n.priority++
-			numParams--
-
-			// if the path doesn't end with the wildcard, then there
-			// will be another non-wildcard subpath starting with '/'
-			if end < max {
-				n.path = path[offset:end]
-				offset = end
-
-				child := &node{
-					maxParams: numParams,
-					priority:  1,
-				}
-				n.children = []*node{child}
-				n = child
+			if j < len(child.path) {
+				child.split(j)
 			}
-
-		} else { // catchAll
-			if end != max || numParams > 1 {
-				panic("catch-all routes are only allowed at the end of the path in path '" + fullPath + "'")
+			if j == len(path) {
+				return child
 			}
+			n, path = child, path[j:]
+			continue walk
+		}
 
-			if len(n.path) > 0 && n.path[len(n.path)-1] == '/' {
-				panic("catch-all conflicts with existing handle for the path segment root in path '" + fullPath + "'")
-			}
+		child := &node{path: path, fullPath: fullPath}
+		// []byte for proper unicode char conversion, see #65
+		n.indices += string([]byte{path[0]})
+		n.children = append(n.children, child)
+		n.incrementChildPrio(len(n.indices) - 1)
+		return child
+	}
+}
 
-			// currently fixed width 1 for '/'
-			i--
-			if path[i] != '/' {
-				panic("no / before catch-all in path '" + fullPath + "'")
-			}
+// split splits the static node at i, moving the rest of its path and all of
+// its children to a new child.
+func (n *node) split(i int) {
+	child := &node{
+		path:     n.path[i:],
+		indices:  n.indices,
+		children: n.children,
+		params:   n.params,
+		catchAll: n.catchAll,
+		handlers: n.handlers,
+		fullPath: n.fullPath,
+		priority: n.priority - 1,
+	}
 
-			n.path = path[offset:i]
+	n.path = n.path[:i]
+	// []byte for proper unicode char conversion, see #65
+	n.indices = string([]byte{child.path[0]})
+	n.children = []*node{child}
+	n.params = nil
+	n.catchAll = nil
+	n.handlers = nil
+}
 
-			// first node: catchAll node with empty path
-			child := &node{
-				wildChild: true,
-				nType:     catchAll,
-				maxParams: 1,
-			}
-			n.children = []*node{child}
-			n.indices = string(path[i])
-			n = child
-			n.priority++
-
-			// second node: node holding the variable
-			child = &node{
-				path:      path[i:],
-				nType:     catchAll,
-				maxParams: 1,
-				handlers:  handlers,
-				priority:  1,
+// insertParam returns the param node for tok below n. Params of different
+// constraints may share a position, they are tried in order of registration
+// with the constrained ones first.
+func (n *node) insertParam(tok pathToken, fullPath string) (*node, error) {
+	for _, child := range n.params {
+		if child.path == tok.text {
+			child.priority++
+			return child, nil
+		}
+		// same constraint (or none) but another name
+		if child.path[len(child.key)+1:] == tok.text[len(tok.name)+1:] {
+			return nil, &RouteConflictError{
+				Path:     fullPath,
+				Existing: child.fullPath,
+				Reason:   "wildcards '" + tok.text + "' and '" + child.path + "' match the same values",
 			}
-			n.children = []*node{child}
+		}
+	}
 
-			return
+	child := &node{
+		path:       tok.text,
+		fullPath:   fullPath,
+		priority:   1,
+		nType:      param,
+		key:        tok.name,
+		constraint: tok.constraint,
+	}
+	i := len(n.params)
+	if child.constraint != nil {
+		for i > 0 && n.params[i-1].constraint == nil {
+			i--
 		}
 	}
+	n.params = append(n.params, nil)
+	copy(n.params[i+1:], n.params[i:])
+	n.params[i] = child
+	return child, nil
+}
 
-	// insert remaining path part and handle to the leaf
-	n.path = path[offset:]
-	n.handlers = handlers
+// insertCatchAll returns the catchAll node for tok below n.
+func (n *node) insertCatchAll(tok pathToken, fullPath string) (*node, error) {
+	if child := n.catchAll; child != nil {
+		if child.path != tok.text {
+			return nil, &RouteConflictError{
+				Path:     fullPath,
+				Existing: child.fullPath,
+				Reason:   "catch-all '" + tok.text + "' conflicts with catch-all '" + child.path + "'",
+			}
+		}
+		child.priority++
+		return child, nil
+	}
+
+	n.catchAll = &node{
+		path:       tok.text,
+		fullPath:   fullPath,
+		priority:   1,
+		nType:      catchAll,
+		key:        tok.name,
+		constraint: tok.constraint,
+	}
+	return n.catchAll, nil
 }
 
 // getValue returns the handle registered with the given path (key). The values of
-// wildcards are saved to a map.
+// wildcards are appended to po.
 // If no handle can be found, a TSR (trailing slash redirect) recommendation is
 // made if a handle exists with an extra (without the) trailing slash for the
 // given path.
 func (n *node) getValue(path string, po Params, unescape bool) (handlers HandlersChain, p Params, tsr bool) {
-	p = po
-walk: // Outer loop for walking the tree
-	for {
-		if len(path) > len(n.path) {
-			if path[:len(n.path)] == n.path {
-				path = path[len(n.path):]
-				// If this node does not have a wildcard (param or catchAll)
-				// child,  we can just look up the next child node and continue
-				// to walk down the tree
-				if !n.wildChild {
-					c := path[0]
-					for i := 0; i < len(n.indices); i++ {
-						if c == n.indices[i] {
-							n = n.children[i]
-							continue walk
-						}
-					}
-
-					// Nothing found.
-					// We can recommend to redirect to the same URL without a
-					// trailing slash if a leaf exists for that path.
-					tsr = path == "/" && n.handlers != nil
-					return
-				}
+	if handlers, p = n.match(path, po, unescape); handlers != nil {
+		return handlers, p, false
+	}
+
+	if len(path) > 1 && path[len(path)-1] == '/' {
+		handlers, _ = n.match(path[:len(path)-1], po, unescape)
+	} else {
+		handlers, _ = n.match(path+"/", po, unescape)
+	}
+	return nil, po, handlers != nil
+}
+
+// match returns the handlers for path below n, which already matched the
+// path before. Children which can not match the rest of the path give way
+// to the next child in order.
+func (n *node) match(path string, p Params, unescape bool) (HandlersChain, Params) {
+	if path == "" {
+		return n.handlers, p
+	}
 
-				// handle wildcard child
-				n = n.children[0]
-				switch n.nType {
-				case param:
-					// find param end (either '/' or path end)
-					end := 0
-					for end < len(path) && path[end] != '/' {
-						end++
-					}
-
-					// save param value
-					if cap(p) < int(n.maxParams) {
-						p = make(Params, 0, n.maxParams)
-					}
-					i := len(p)
-					p = p[:i+1] // expand slice within preallocated capacity
-					p[i].Key = n.path[1:]
-					val := path[:end]
-					if unescape {
-						var err error
-						if p[i].Value, err = url.QueryUnescape(val); err != nil {
-							p[i].Value = val // fallback, in case of error
-						}
-					} else {
-						p[i].Value = val
-					}
-
-					// we need to go deeper!
-					if end < len(path) {
-						if len(n.children) > 0 {
-							path = path[end:]
-							n = n.children[0]
-							continue walk
-						}
-
-						// ... but we can't
-						tsr = len(path) == end+1
-						return
-					}
-
-					if handlers = n.handlers; handlers != nil {
-						return
-					}
-					if len(n.children) == 1 {
-						// No handle found. Check if a handle for this path + a
-						// trailing slash exists for TSR recommendation
-						n = n.children[0]
-						tsr = n.path == "/" && n.handlers != nil
-					}
-
-					return
-
-				case catchAll:
-					// save param value
-					if cap(p) < int(n.maxParams) {
-						p = make(Params, 0, n.maxParams)
-					}
-					i := len(p)
-					p = p[:i+1] // expand slice within preallocated capacity
-					p[i].Key = n.path[2:]
-					if unescape {
-						var err error
-						if p[i].Value, err = url.QueryUnescape(path); err != nil {
-							p[i].Value = path // fallback, in case of error
-						}
-					} else {
-						p[i].Value = path
-					}
-
-					handlers = n.handlers
-					return
-
-				default:
-					panic("invalid node type")
+	c := path[0]
+	for i := 0; i < len(n.indices); i++ {
+		if c == n.indices[i] {
+			child := n.children[i]
+			if strings.HasPrefix(path, child.path) {
+				if handlers, ps := child.match(path[len(child.path):], p, unescape); handlers != nil {
+					return handlers, ps
 				}
 			}
-		} else if path == n.path {
-			// We should have reached the node containing the handle.
-			// Check if this node has a handle registered.
-			if handlers = n.handlers; handlers != nil {
-				return
-			}
+			break
+		}
+	}
 
-			if path == "/" && n.wildChild && n.nType != root {
-				tsr = true
-				return
+	if len(n.params) > 0 {
+		// find segment end (either '/' or path end)
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		for _, child := range n.params {
+			if handlers, ps := child.matchParam(path, end, p, unescape); handlers != nil {
+				return handlers, ps
 			}
+		}
+	}
 
-			// No handle found. Check if a handle for this path + a
-			// trailing slash exists for trailing slash recommendation
-			for i := 0; i < len(n.indices); i++ {
-				if n.indices[i] == '/' {
-					n = n.children[i]
-					tsr = (len(n.path) == 1 && n.handlers != nil) ||
-						(n.nType == catchAll && n.children[0].handlers != nil)
-					return
-				}
-			}
+	if child := n.catchAll; child != nil && c == '/' {
+		value := unescapeValue(path, unescape)
+		if child.constraint == nil || child.constraint(value) {
+			return child.handlers, append(p, Param{Key: child.key, Value: value})
+		}
+	}
+	return nil, p
+}
 
-			return
+// matchParam matches the param node n against path, whose segment ends at
+// end. The value ends at the segment end, or before static text of the
+// children within the segment, shortest value first, e.g. ":name.:ext"
+// matches "a.tar.gz" with "a" and "tar.gz".
+func (n *node) matchParam(path string, end int, p Params, unescape bool) (HandlersChain, Params) {
+	for i := 1; i <= end; i++ {
+		if i < end && strings.IndexByte(n.indices, path[i]) < 0 {
+			continue
 		}
 
-		// Nothing found. We can recommend to redirect to the same URL with an
-		// extra trailing slash if a leaf exists for that path
-		tsr = (path == "/") ||
-			(len(n.path) == len(path)+1 && n.path[len(path)] == '/' &&
-				path == n.path[:len(n.path)-1] && n.handlers != nil)
-		return
+		value := unescapeValue(path[:i], unescape)
+		if n.constraint != nil && !n.constraint(value) {
+			// a value rejected by the constraint matches no route
+			continue
+		}
+		if handlers, ps := n.match(path[i:], append(p, Param{Key: n.key, Value: value}), unescape); handlers != nil {
+			return handlers, ps
+		}
 	}
+	return nil, p
+}
+
+func unescapeValue(value string, unescape bool) string {
+	if unescape {
+		if v, err := url.QueryUnescape(value); err == nil {
+			return v
+		}
+	}
+	return value
 }
 
 // findCaseInsensitivePath makes a case-insensitive lookup of the given path and tries to find a handler.
@@ -504,117 +518,107 @@ walk: // Outer loop for walking the tree
 // It returns the case-corrected path and a bool indicating whether the lookup
 // was successful.
 func (n *node) findCaseInsensitivePath(path string, fixTrailingSlash bool) (ciPath []byte, found bool) {
-	ciPath = make([]byte, 0, len(path)+1) // preallocate enough memory
-
-	// Outer loop for walking the tree
-	for len(path) >= len(n.path) && strings.ToLower(path[:len(n.path)]) == strings.ToLower(n.path) {
-		path = path[len(n.path):]
-		ciPath = append(ciPath, n.path...)
-
-		if len(path) > 0 {
-			// If this node does not have a wildcard (param or catchAll) child,
-			// we can just look up the next child node and continue to walk down
-			// the tree
-			if !n.wildChild {
-				r := unicode.ToLower(rune(path[0]))
-				for i, index := range n.indices {
-					// must use recursive approach since both index and
-					// ToLower(index) could exist. We must check both.
-					if r == unicode.ToLower(index) {
-						out, found := n.children[i].findCaseInsensitivePath(path, fixTrailingSlash)
-						if found {
-							return append(ciPath, out...), true
-						}
-					}
-				}
+	buf := make([]byte, 0, len(path)+1) // preallocate enough memory for new path
+	ciPath = n.findCaseInsensitivePathRec(path, buf, "")
 
-				// Nothing found. We can recommend to redirect to the same URL
-				// without a trailing slash if a leaf exists for that path
-				found = fixTrailingSlash && path == "/" && n.handlers != nil
-				return
+	if ciPath == nil && fixTrailingSlash && len(path) > 1 {
+		if path[len(path)-1] == '/' {
+			ciPath = n.findCaseInsensitivePathRec(path[:len(path)-1], buf, "")
+		} else {
+			ciPath = n.findCaseInsensitivePathRec(path+"/", buf, "")
+		}
+	}
+	return ciPath, ciPath != nil
+}
+
+// findCaseInsensitivePathRec is the recursive lookup used by findCaseInsensitivePath,
+// trying the children below n in the same order as match. Both the lower and
+// the upper case variant of a path byte might be an index, so all static
+// children are tried. Node paths may split a multi-byte rune, its leading
+// bytes are passed down as pending until the rune is complete.
+func (n *node) findCaseInsensitivePathRec(path string, ciPath []byte, pending string) []byte {
+	if path == "" {
+		if n.handlers != nil && pending == "" {
+			return ciPath
+		}
+		return nil
+	}
+
+	for _, child := range n.children {
+		text := pending + child.path
+		rest := text[len(text)-incompleteRune(text):]
+		if tail, ok := foldPrefix(path, text[:len(text)-len(rest)]); ok {
+			if out := child.findCaseInsensitivePathRec(tail, append(ciPath, child.path...), rest); out != nil {
+				return out
 			}
+		}
+	}
+	if pending != "" {
+		return nil
+	}
 
-			n = n.children[0]
-			switch n.nType {
-			case param:
-				// find param end (either '/' or path end)
-				k := 0
-				for k < len(path) && path[k] != '/' {
-					k++
+	if len(n.params) > 0 {
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		for _, child := range n.params {
+			for i := 1; i <= end; i++ {
+				if i < end && strings.IndexByte(child.indices, path[i]) < 0 {
+					continue
 				}
-
-				// add param value to case insensitive path
-				ciPath = append(ciPath, path[:k]...)
-
-				// we need to go deeper!
-				if k < len(path) {
-					if len(n.children) > 0 {
-						path = path[k:]
-						n = n.children[0]
-						continue
-					}
-
-					// ... but we can't
-					if fixTrailingSlash && len(path) == k+1 {
-						return ciPath, true
-					}
-					return
+				if child.constraint != nil && !child.constraint(path[:i]) {
+					continue
 				}
-
-				if n.handlers != nil {
-					return ciPath, true
-				} else if fixTrailingSlash && len(n.children) == 1 {
-					// No handle found. Check if a handle for this path + a
-					// trailing slash exists
-					n = n.children[0]
-					if n.path == "/" && n.handlers != nil {
-						return append(ciPath, '/'), true
-					}
+				// add param value to case insensitive path
+				if out := child.findCaseInsensitivePathRec(path[i:], append(ciPath, path[:i]...), ""); out != nil {
+					return out
 				}
-				return
-
-			case catchAll:
-				return append(ciPath, path...), true
-
-			default:
-				panic("invalid node type")
-			}
-		} else {
-			// We should have reached the node containing the handle.
-			// Check if this node has a handle registered.
-			if n.handlers != nil {
-				return ciPath, true
 			}
+		}
+	}
 
-			// No handle found.
-			// Try to fix the path by adding a trailing slash
-			if fixTrailingSlash {
-				for i := 0; i < len(n.indices); i++ {
-					if n.indices[i] == '/' {
-						n = n.children[i]
-						if (len(n.path) == 1 && n.handlers != nil) ||
-							(n.nType == catchAll && n.children[0].handlers != nil) {
-							return append(ciPath, '/'), true
-						}
-						return
-					}
-				}
+	if child := n.catchAll; child != nil && path[0] == '/' && child.handlers != nil {
+		if child.constraint == nil || child.constraint(path) {
+			return append(ciPath, path...)
+		}
+	}
+	return nil
+}
+
+// incompleteRune returns the number of bytes of the incomplete rune at the
+// end of s.
+func incompleteRune(s string) int {
+	for i := len(s) - 1; i >= 0 && i >= len(s)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(s[i]) {
+			if utf8.FullRuneInString(s[i:]) {
+				return 0
 			}
-			return
+			return len(s) - i
 		}
 	}
+	return 0
+}
 
-	// Nothing found.
-	// Try to fix the path by adding / removing a trailing slash
-	if fixTrailingSlash {
-		if path == "/" {
-			return ciPath, true
+// foldPrefix reports whether s starts with prefix under Unicode case folding
+// and returns the rest of s. Invalid bytes must match exactly.
+func foldPrefix(s, prefix string) (string, bool) {
+	for prefix != "" {
+		if s == "" {
+			return "", false
 		}
-		if len(path)+1 == len(n.path) && n.path[len(path)] == '/' &&
-			strings.ToLower(path) == strings.ToLower(n.path[:len(path)]) &&
-			n.handlers != nil {
-			return append(ciPath, n.path...), true
+
+		pr, pn := utf8.DecodeRuneInString(prefix)
+		sr, sn := utf8.DecodeRuneInString(s)
+		if pr == utf8.RuneError && pn == 1 {
+			if s[0] != prefix[0] {
+				return "", false
+			}
+			sn = 1
+		} else if pr != sr && !strings.EqualFold(prefix[:pn], s[:sn]) {
+			return "", false
 		}
+		prefix, s = prefix[pn:], s[sn:]
 	}
-	return
+	return s, true
 }
@@ -0,0 +1,268 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func fakeHandlers() HandlersChain {
+	return HandlersChain{func(*Context) {}}
+}
+
+func TestFindCaseInsensitivePath(t *testing.T) {
+	tree := new(node)
+	for _, path := range []string{
+		"/hi",
+		"/users/:id/edit",
+		"/src/*filepath",
+		"/café",
+		"/cafè",
+		"/Ünïcode/x",
+		"/dir/",
+	} {
+		if err := tree.addRoute(path, fakeHandlers()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path     string
+		fixSlash bool
+		want     string
+		found    bool
+	}{
+		{"/HI", false, "/hi", true},
+		{"/USERS/Ab/EDIT", false, "/users/Ab/edit", true},
+		{"/SRC/Foo/Bar", false, "/src/Foo/Bar", true},
+		{"/CAFÉ", false, "/café", true},
+		{"/CAFÈ", false, "/cafè", true},
+		{"/üNÏCODE/X", false, "/Ünïcode/x", true},
+		{"/HI/", false, "", false},
+		{"/HI/", true, "/hi", true},
+		{"/DIR", true, "/dir/", true},
+		{"/USERS/Ab/EDIT/", true, "/users/Ab/edit", true},
+		{"/nope", true, "", false},
+		{"/users//edit", true, "", false},
+	}
+	for _, tt := range tests {
+		out, found := tree.findCaseInsensitivePath(tt.path, tt.fixSlash)
+		if found != tt.found || string(out) != tt.want {
+			t.Errorf("%s (fix %v): got %q %v, want %q %v", tt.path, tt.fixSlash, out, found, tt.want, tt.found)
+		}
+	}
+}
+
+func TestRedirectFixedPath(t *testing.T) {
+	core := New()
+	core.RedirectFixedPath = true
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut} {
+		core.Handle(method, "/users/:id", func(c *Context) {})
+		core.Handle(method, "/static/*filepath", func(c *Context) {})
+		core.Handle(method, "/straße", func(c *Context) {})
+	}
+
+	tests := []struct {
+		method   string
+		path     string
+		code     int
+		location string
+	}{
+		{http.MethodGet, "/USERS/Ab", http.StatusMovedPermanently, "/users/Ab"},
+		{http.MethodHead, "/USERS/Ab", http.StatusMovedPermanently, "/users/Ab"},
+		{http.MethodPost, "/USERS/Ab", http.StatusPermanentRedirect, "/users/Ab"},
+		{http.MethodPut, "/users/Ab/", http.StatusPermanentRedirect, "/users/Ab"},
+		{http.MethodGet, "/STATIC/Css/App.css", http.StatusMovedPermanently, "/static/Css/App.css"},
+		{http.MethodGet, "/STRASSE", http.StatusNotFound, ""},
+		{http.MethodGet, "/STRAßE", http.StatusMovedPermanently, "/stra%C3%9Fe"},
+		{http.MethodGet, "/..//USERS/x", http.StatusMovedPermanently, "/users/x"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/", nil)
+		req.URL.Path = tt.path
+		w := httptest.NewRecorder()
+		core.ServeHTTP(w, req)
+
+		if w.Code != tt.code || w.Header().Get("Location") != tt.location {
+			t.Errorf("%s %s: got %d %q, want %d %q",
+				tt.method, tt.path, w.Code, w.Header().Get("Location"), tt.code, tt.location)
+		}
+	}
+}
+
+func TestRedirectHeadByGetRoute(t *testing.T) {
+	core := New()
+	core.RedirectFixedPath = true
+	core.GET("/users/:id", func(c *Context) {})
+
+	// with and without HEAD routes of other paths
+	for _, headRoutes := range []bool{false, true} {
+		if headRoutes {
+			core.HEAD("/health", func(c *Context) {})
+		}
+		tests := []struct {
+			path, location string
+			code           int
+		}{
+			{"/users/1", "", http.StatusOK},
+			{"/users/1/", "/users/1", http.StatusMovedPermanently},
+			{"/USERS/1", "/users/1", http.StatusMovedPermanently},
+			{"/posts/1", "", http.StatusNotFound},
+		}
+		for _, tt := range tests {
+			req := httptest.NewRequest(http.MethodHead, tt.path, nil)
+			w := httptest.NewRecorder()
+			core.ServeHTTP(w, req)
+
+			if w.Code != tt.code || w.Header().Get("Location") != tt.location {
+				t.Errorf("HEAD %s (HEAD routes %v): got %d %q, want %d %q",
+					tt.path, headRoutes, w.Code, w.Header().Get("Location"), tt.code, tt.location)
+			}
+		}
+	}
+}
+
+func TestRedirectTrailingSlashNoOpenRedirect(t *testing.T) {
+	core := New()
+	// matches "//evil.com" but not "//evil.com/"
+	core.GET("/*all<.*[^/]>", func(c *Context) {})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.URL.Path = "//evil.com/"
+	w := httptest.NewRecorder()
+	core.ServeHTTP(w, req)
+
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/evil.com" {
+		t.Errorf("got %d %q, want 301 %q", w.Code, w.Header().Get("Location"), "/evil.com")
+	}
+}
+
+func TestTreeLookup(t *testing.T) {
+	tree := new(node)
+	var matched string
+	for _, route := range []string{
+		"/",
+		"/users/new",
+		"/users/:id<int>",
+		"/users/:name",
+		"/users/:name/posts",
+		"/files/:name.:ext",
+		"/docs/:page?",
+		"/static/*filepath",
+		"/v:major<int>/status",
+		"/items/:id<uuid>",
+		"/items/:slug<alpha>",
+	} {
+		route := route
+		if err := tree.addRoute(route, HandlersChain{func(*Context) { matched = route }}); err != nil {
+			t.Fatalf("add %s: %v", route, err)
+		}
+	}
+
+	const uuid = "123e4567-e89b-12d3-a456-426614174000"
+	tests := []struct {
+		path   string
+		route  string // "" for no match
+		params Params
+		tsr    bool
+	}{
+		{path: "/", route: "/"},
+		{path: "/users/new", route: "/users/new"},
+		// static wins, a longer value still matches the param
+		{path: "/users/newest", route: "/users/:name", params: Params{{"name", "newest"}}},
+		// constrained params are tried first
+		{path: "/users/42", route: "/users/:id<int>", params: Params{{"id", "42"}}},
+		{path: "/users/bob", route: "/users/:name", params: Params{{"name", "bob"}}},
+		{path: "/users/42/posts", route: "/users/:name/posts", params: Params{{"name", "42"}}},
+		{path: "/users/new/", tsr: true},
+		{path: "/users/bob/posts/", tsr: true},
+		// a param ends before the static text following it in its segment
+		{path: "/files/report.pdf", route: "/files/:name.:ext", params: Params{{"name", "report"}, {"ext", "pdf"}}},
+		{path: "/files/report", route: ""},
+		{path: "/docs", route: "/docs/:page?"},
+		{path: "/docs/intro", route: "/docs/:page?", params: Params{{"page", "intro"}}},
+		{path: "/static/css/app.css", route: "/static/*filepath", params: Params{{"filepath", "/css/app.css"}}},
+		{path: "/static/", route: "/static/*filepath", params: Params{{"filepath", "/"}}},
+		{path: "/v2/status", route: "/v:major<int>/status", params: Params{{"major", "2"}}},
+		{path: "/vx/status", route: ""},
+		{path: "/items/" + uuid, route: "/items/:id<uuid>", params: Params{{"id", uuid}}},
+		{path: "/items/abc", route: "/items/:slug<alpha>", params: Params{{"slug", "abc"}}},
+		{path: "/items/abc1", route: ""},
+	}
+	for _, tt := range tests {
+		matched = ""
+		handlers, params, tsr := tree.getValue(tt.path, nil, false)
+		if handlers != nil {
+			handlers.Last()(nil)
+		}
+		if matched != tt.route {
+			t.Errorf("%s: matched %q, want %q", tt.path, matched, tt.route)
+		}
+		if len(params) != len(tt.params) || len(params) > 0 && !reflect.DeepEqual(params, tt.params) {
+			t.Errorf("%s: params %v, want %v", tt.path, params, tt.params)
+		}
+		if tsr != tt.tsr {
+			t.Errorf("%s: tsr %v, want %v", tt.path, tsr, tt.tsr)
+		}
+	}
+}
+
+func TestTreeAddRouteErrors(t *testing.T) {
+	tests := []struct {
+		existing, path string
+		conflict       string // existing route of a RouteConflictError
+		err            string
+	}{
+		{"/a/:id", "/a/:name", "/a/:id", "wildcards ':name' and ':id' match the same values"},
+		{"/a/:id<int>", "/a/:n<int>", "/a/:id<int>", "wildcards ':n<int>' and ':id<int>' match the same values"},
+		{"/b/*x", "/b/*y", "/b/*x", "catch-all '*y' conflicts with catch-all '*x'"},
+		{"/c", "/c", "/c", "handlers are already registered"},
+		{"/d/:id?", "/d", "/d/:id?", "handlers are already registered"},
+		{"", "/e/:", "", "wildcards must be named with a non-empty name"},
+		{"", "/f/*x/y", "", "catch-all routes are only allowed at the end of the path"},
+		{"", "/g/x*y", "", "no / before catch-all"},
+		{"", "/h/:a:b", "", "must be separated by static text"},
+		{"", "/i/:id?/x", "", "must be the last segment of the path"},
+		{"", "/j/:id<int", "", "unterminated constraint"},
+		{"", "/k/:id<[>", "", "invalid constraint '['"},
+	}
+	for _, tt := range tests {
+		tree := new(node)
+		if tt.existing != "" {
+			if err := tree.addRoute(tt.existing, fakeHandlers()); err != nil {
+				t.Fatalf("add %s: %v", tt.existing, err)
+			}
+		}
+
+		err := tree.addRoute(tt.path, fakeHandlers())
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("add %s after %q: got %v, want %q", tt.path, tt.existing, err, tt.err)
+			continue
+		}
+		conflict, ok := err.(*RouteConflictError)
+		if tt.conflict == "" && ok {
+			t.Errorf("add %s: got conflict %v, want a path error", tt.path, err)
+		}
+		if tt.conflict != "" && (!ok || conflict.Existing != tt.conflict || conflict.Path != tt.path) {
+			t.Errorf("add %s: got %#v, want conflict with %s", tt.path, err, tt.conflict)
+		}
+	}
+}
+
+func TestCoreRouteConflictPanics(t *testing.T) {
+	core := New()
+	core.GET("/users/:id", func(c *Context) {})
+	defer func() {
+		err, ok := recover().(*RouteConflictError)
+		if !ok || err.Method != http.MethodGet {
+			t.Errorf("got %v, want a RouteConflictError of GET", err)
+		}
+	}()
+	core.GET("/users/:name", func(c *Context) {})
+}
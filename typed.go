@@ -0,0 +1,99 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"net/http"
+	"reflect"
+)
+
+// typedOffers are the formats a Typed handler responds with.
+var typedOffers = []string{MIMEJSON, MIMEXML, MIMEYAML, MIMEMsgPack}
+
+// Typed adapts fn to a HandlerFunc, it can be registered like any handler.
+//
+// The request is decoded into Req: path params by the `uri` tag, then the
+// body by the binding matching the Content-Type, or the query of requests
+// without body. Req is validated by Core.Validator before fn is called.
+// Binding errors are reported by Context.Error as ErrorTypeBind and abort
+// with 400, errors returned by fn are handled like by WrapErr.
+//
+// Resp is rendered in the format negotiated by the Accept header with the
+// status set by fn, 200 by default, unless fn wrote the response itself. The
+// format is negotiated before fn is called, requests accepting none of them
+// abort with 406 without calling fn.
+// Req and Resp are exposed by RouteInfo.Request and RouteInfo.Response.
+func Typed[Req, Resp any](fn func(c *Context, req Req) (Resp, error)) HandlerFunc {
+	assert1(fn != nil, "handler can not be nil")
+
+	reqType := reflect.TypeOf((*Req)(nil)).Elem()
+	respType := reflect.TypeOf((*Resp)(nil)).Elem()
+
+	desc := &handlerDesc{
+		name:     nameOfFunction(fn),
+		request:  reqType,
+		response: respType,
+	}
+	return describedHandler(desc, func(c *Context) {
+		var req Req
+		target := interface{}(&req)
+		if reqType.Kind() == reflect.Ptr {
+			ptr := reflect.New(reqType.Elem())
+			req, target = ptr.Interface().(Req), ptr.Interface()
+		}
+
+		if err := c.bindTyped(target); err != nil {
+			abortWithError(c, c.Error(err).SetType(ErrorTypeBind))
+			return
+		}
+
+		format := c.NegotiateFormat(typedOffers...)
+		if format == "" {
+			c.AbortWithStatus(http.StatusNotAcceptable)
+			return
+		}
+
+		resp, err := fn(c, req)
+		if err != nil {
+			abortWithError(c, c.Error(err))
+			return
+		}
+		if c.Writer.Written() {
+			return
+		}
+
+		c.Render(c.Writer.Status(), c.renderFor(format, resp))
+	})
+}
+
+// bindTyped decodes the path params, and the body or query into obj and
+// validates it once all sources are bound.
+func (c *Context) bindTyped(obj interface{}) error {
+	kind := reflect.TypeOf(obj).Elem().Kind()
+	if kind != reflect.Struct && kind != reflect.Map {
+		return nil
+	}
+
+	if kind == reflect.Struct && len(c.Params) > 0 {
+		if err := BindingURI.BindUri(c.Params, obj); err != nil {
+			return err
+		}
+	}
+
+	req := c.Request
+	var b Binding
+	switch {
+	case req.Method == http.MethodGet || req.Method == http.MethodHead ||
+		req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0:
+		b = BindingQuery
+	default:
+		b = bindingFor(req.Method, c.ContentType())
+	}
+	if err := c.configure(b).Bind(req, obj); err != nil {
+		return err
+	}
+
+	return c.validate(obj)
+}
@@ -0,0 +1,57 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type typedItem struct {
+	ID   int    `uri:"id" json:"id" xml:"id"`
+	Name string `form:"name" json:"name" xml:"name"`
+}
+
+func TestTypedNegotiate(t *testing.T) {
+	calls := 0
+	core := New()
+	core.GET("/items/:id", Typed(func(c *Context, req typedItem) (typedItem, error) {
+		calls++
+		c.Status(http.StatusCreated)
+		return req, nil
+	}))
+
+	tests := []struct {
+		accept      string
+		code        int
+		contentType string
+		body        string
+		called      bool
+	}{
+		{"", http.StatusCreated, jsonContent, `{"id":7,"name":"pen"}`, true},
+		{"application/xml", http.StatusCreated, xmlContent, "<typedItem><id>7</id><name>pen</name></typedItem>", true},
+		{"text/html", http.StatusNotAcceptable, "", "", false},
+	}
+	for _, tt := range tests {
+		calls = 0
+		req := httptest.NewRequest(http.MethodGet, "/items/7?name=pen", nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		core.ServeHTTP(w, req)
+
+		if w.Code != tt.code || w.Header().Get("Content-Type") != tt.contentType ||
+			strings.TrimSpace(w.Body.String()) != tt.body {
+			t.Errorf("Accept %q: got %d %q %q, want %d %q %q", tt.accept,
+				w.Code, w.Header().Get("Content-Type"), w.Body.String(), tt.code, tt.contentType, tt.body)
+		}
+		if called := calls == 1; called != tt.called {
+			t.Errorf("Accept %q: handler called %d times, want called %v", tt.accept, calls, tt.called)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+var (
+	// ErrRouteNotFound is returned by URL for an unknown route name.
+	ErrRouteNotFound = errors.New("klyn: route not found")
+	// ErrMissingParam is returned by URL when a path param has no value.
+	ErrMissingParam = errors.New("klyn: missing route param")
+)
+
+// URL builds the path of the route with the given name registered on the
+// host of the group, filling its params from the key-value pairs, e.g.
+//
+//	core.GET("/users/:id", show).Name("user.show")
+//	core.URL("user.show", "id", "42") // "/users/42"
+//
+// Param values are path escaped, the value of a catch-all param may contain
+// slashes which are kept. A missing optional param is left out with its '/'.
+// The URL of a route of a host router includes the host, its params are
+// filled as well, e.g. "//acme.example.com/users/42". Routes of wildcard
+// hosts give the path only.
+func (rg *RouterGroup) URL(name string, pairs ...string) (string, error) {
+	return rg.core.url(rg.host, name, pairs)
+}
+
+// URL builds the URL of the named route of the host of the request, or of
+// Core if the host has no route of that name, see RouterGroup.URL.
+func (c *Context) URL(name string, pairs ...string) (string, error) {
+	host := c.core.hostOf(c)
+	if host != nil {
+		if _, ok := c.core.namedRoutes[namedRouteKey(host.pattern, name)]; !ok {
+			host = nil
+		}
+	}
+	return c.core.url(host, name, pairs)
+}
+
+func (core *Core) url(host *hostRouter, name string, pairs []string) (string, error) {
+	pattern := ""
+	if host != nil {
+		pattern = host.pattern
+	}
+	info, ok := core.namedRoutes[namedRouteKey(pattern, name)]
+	if !ok {
+		return "", fmt.Errorf("%w: '%s'", ErrRouteNotFound, name)
+	}
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("klyn: odd number of params for route '%s'", name)
+	}
+
+	values := make(map[string]string, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		values[pairs[i]] = pairs[i+1]
+	}
+
+	return buildPath(name, hostPath(info.Host, info.Path), values)
+}
+
+func buildPath(name, path string, values map[string]string) (string, error) {
+	tokens, err := parsePath(path)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	used := 0
+	for _, tok := range tokens {
+		if tok.kind == static {
+			sb.WriteString(tok.text)
+			continue
+		}
+
+		value, ok := values[tok.name]
+		if ok {
+			used++
+		}
+
+		switch {
+		case tok.kind == catchAll && ok:
+			for _, seg := range strings.Split(strings.TrimPrefix(value, "/"), "/") {
+				sb.WriteByte('/')
+				sb.WriteString(url.PathEscape(seg))
+			}
+		case tok.kind == param && value != "":
+			sb.WriteString(url.PathEscape(value))
+		case tok.optional:
+			s := strings.TrimSuffix(sb.String(), "/")
+			if s == "" {
+				s = "/"
+			}
+			sb.Reset()
+			sb.WriteString(s)
+		default:
+			return "", fmt.Errorf("%w '%s' of route '%s'", ErrMissingParam, tok.name, name)
+		}
+	}
+
+	if used != len(values) {
+		known := make(map[string]bool, used)
+		for _, key := range paramNames(path) {
+			known[key] = true
+		}
+		for key := range values {
+			if !known[key] {
+				return "", fmt.Errorf("klyn: unknown param '%s' for route '%s'", key, name)
+			}
+		}
+	}
+
+	return sb.String(), nil
+}
@@ -28,8 +28,35 @@ func joinPaths(absolutePath, relativePath string) string {
 	return finalPath
 }
 
+// cleanPath is path.Clean for URL paths: the result always begins with '/'
+// and keeps the trailing slash of p.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+
+	np := path.Clean(p)
+	if lastChar(p) == '/' && np != "/" {
+		np += "/"
+	}
+	return np
+}
+
 func assert1(guard bool, text string) {
 	if !guard {
 		panic(text)
 	}
 }
+
+// filterFlags strips the parameters of a header value, e.g. "; charset=utf-8".
+func filterFlags(content string) string {
+	for i, char := range content {
+		if char == ' ' || char == ';' {
+			return content[:i]
+		}
+	}
+	return content
+}
@@ -0,0 +1,426 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// StructValidator is the validation engine run on every bound struct.
+type StructValidator interface {
+	// ValidateStruct validates a struct, a pointer to struct or a slice of them.
+	// Any other type is ignored and nil is returned.
+	ValidateStruct(obj interface{}) error
+
+	// Engine returns the underlying validator.
+	Engine() interface{}
+}
+
+// ValidationFunc reports whether field satisfies the rule with the given param.
+// field is never a pointer, nil pointers are handled before rules run.
+type ValidationFunc func(field reflect.Value, param string) bool
+
+// FieldError is a single failed rule.
+type FieldError struct {
+	// Namespace is the path of the field from the root struct, e.g. "User.Emails[0]".
+	Namespace string
+	Field     string
+	Tag       string
+	Param     string
+	Value     interface{}
+}
+
+func (e FieldError) Error() string {
+	rule := e.Tag
+	if e.Param != "" {
+		rule += "=" + e.Param
+	}
+	return "field '" + e.Namespace + "' failed on the '" + rule + "' rule"
+}
+
+// ValidationErrors is the list of failed rules returned by the default validator.
+type ValidationErrors []FieldError
+
+func (ve ValidationErrors) Error() string {
+	msgs := make([]string, len(ve))
+	for i, e := range ve {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// DefaultValidator validates struct fields by the `validate` tag.
+//
+// Builtin rules:
+//
+//	required       value is not the zero value (non-empty for slice and map)
+//	omitempty      skip the remaining rules if the value is zero
+//	min=n, max=n   bounds of numbers, or of the length of strings, slices and maps
+//	len=n          exact length of strings, slices and maps, or exact number
+//	oneof=a b c    value is one of the space separated options
+//	email          value is an email address
+//	regexp=expr    value matches expr, must be the last rule of the tag
+//
+// Nested structs and slices, arrays and maps of structs are validated
+// recursively, use `validate:"-"` to skip a field.
+type DefaultValidator struct {
+	mu    sync.RWMutex
+	rules map[string]ValidationFunc
+	cache sync.Map // reflect.Type -> []fieldRules
+}
+
+var _ StructValidator = &DefaultValidator{}
+
+// NewValidator returns a DefaultValidator with the builtin rules.
+func NewValidator() *DefaultValidator {
+	v := &DefaultValidator{rules: make(map[string]ValidationFunc)}
+	for name, fn := range builtinRules {
+		v.rules[name] = fn
+	}
+	return v
+}
+
+// RegisterValidation adds (or replaces) a rule usable in the `validate` tag.
+func (v *DefaultValidator) RegisterValidation(name string, fn ValidationFunc) {
+	assert1(name != "" && name != "omitempty" && name != "required", "invalid validation rule name '"+name+"'")
+	assert1(fn != nil, "validation func can not be nil")
+
+	v.mu.Lock()
+	v.rules[name] = fn
+	v.mu.Unlock()
+	v.cache.Range(func(key, _ interface{}) bool {
+		v.cache.Delete(key)
+		return true
+	})
+}
+
+// Engine returns v itself.
+func (v *DefaultValidator) Engine() interface{} {
+	return v
+}
+
+// ValidateStruct implements StructValidator. A `validate` tag with an
+// undefined rule or an invalid regexp is reported as error.
+func (v *DefaultValidator) ValidateStruct(obj interface{}) error {
+	if obj == nil {
+		return nil
+	}
+
+	var errs ValidationErrors
+	if err := v.validateValue(reflect.ValueOf(obj), "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// ValidateType checks the `validate` tags of t and of the structs it
+// contains, Core checks the request types of Typed handlers by it when the
+// route is registered.
+func (v *DefaultValidator) ValidateType(t reflect.Type) error {
+	return v.validateType(t, make(map[reflect.Type]bool))
+}
+
+func (v *DefaultValidator) validateType(t reflect.Type, visited map[reflect.Type]bool) error {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType || visited[t] {
+		return nil
+	}
+	visited[t] = true
+
+	if _, err := v.structRules(t); err != nil {
+		return err
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if sf := t.Field(i); sf.Tag.Get("validate") != "-" {
+			if err := v.validateType(sf.Type, visited); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type rule struct {
+	name  string
+	param string
+	fn    ValidationFunc
+}
+
+type fieldRules struct {
+	index     int
+	name      string
+	required  bool
+	omitEmpty bool
+	rules     []rule
+}
+
+func (v *DefaultValidator) validateValue(rv reflect.Value, ns string, errs *ValidationErrors) error {
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		if rv.Type() != timeType {
+			return v.validateStruct(rv, ns, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			if err := v.validateValue(rv.Index(i), ns+"["+strconv.Itoa(i)+"]", errs); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := rv.MapRange()
+		for iter.Next() {
+			if err := v.validateValue(iter.Value(), ns+"["+fmt.Sprint(iter.Key().Interface())+"]", errs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (v *DefaultValidator) validateStruct(rv reflect.Value, ns string, errs *ValidationErrors) error {
+	if ns == "" {
+		ns = rv.Type().Name()
+	}
+
+	frs, err := v.structRules(rv.Type())
+	if err != nil {
+		return err
+	}
+	for _, fr := range frs {
+		fv := rv.Field(fr.index)
+		fns := ns + "." + fr.name
+
+		if fr.required && !hasValue(fv) {
+			*errs = append(*errs, FieldError{Namespace: fns, Field: fr.name, Tag: "required"})
+			continue
+		}
+
+		for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Ptr || (fr.omitEmpty && !hasValue(fv)) {
+			continue
+		}
+
+		for _, r := range fr.rules {
+			if !r.fn(fv, r.param) {
+				*errs = append(*errs, FieldError{
+					Namespace: fns,
+					Field:     fr.name,
+					Tag:       r.name,
+					Param:     r.param,
+					Value:     valueOf(fv),
+				})
+			}
+		}
+
+		if err := v.validateValue(fv, fns, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func valueOf(fv reflect.Value) interface{} {
+	if fv.CanInterface() {
+		return fv.Interface()
+	}
+	return nil
+}
+
+// structRules parses and caches the `validate` tags of t.
+func (v *DefaultValidator) structRules(t reflect.Type) ([]fieldRules, error) {
+	if cached, ok := v.cache.Load(t); ok {
+		return cached.([]fieldRules), nil
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	var frs []fieldRules
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		tag := sf.Tag.Get("validate")
+		if tag == "-" {
+			continue
+		}
+
+		fr := fieldRules{index: i, name: sf.Name}
+		for tag != "" {
+			var item string
+			if strings.HasPrefix(tag, "regexp=") {
+				item, tag = tag, ""
+			} else if j := strings.IndexByte(tag, ','); j >= 0 {
+				item, tag = tag[:j], tag[j+1:]
+			} else {
+				item, tag = tag, ""
+			}
+
+			name, param := item, ""
+			if j := strings.IndexByte(item, '='); j >= 0 {
+				name, param = item[:j], item[j+1:]
+			}
+
+			switch name {
+			case "":
+			case "required":
+				fr.required = true
+			case "omitempty":
+				fr.omitEmpty = true
+			default:
+				fn, ok := v.rules[name]
+				if !ok {
+					return nil, fmt.Errorf("klyn: undefined validation rule '%s' on field %s.%s", name, t, sf.Name)
+				}
+				switch name {
+				case "regexp":
+					if _, err := compileRegexp(param); err != nil {
+						return nil, fmt.Errorf("klyn: invalid validation regexp '%s' on field %s.%s: %v", param, t, sf.Name, err)
+					}
+				case "min", "max", "len":
+					if _, err := strconv.ParseFloat(param, 64); err != nil {
+						return nil, fmt.Errorf("klyn: invalid validation param '%s' of rule '%s' on field %s.%s", param, name, t, sf.Name)
+					}
+				}
+				fr.rules = append(fr.rules, rule{name: name, param: param, fn: fn})
+			}
+		}
+
+		frs = append(frs, fr)
+	}
+
+	v.cache.Store(t, frs)
+	return frs, nil
+}
+
+func hasValue(fv reflect.Value) bool {
+	switch fv.Kind() {
+	case reflect.Slice, reflect.Map:
+		return fv.Len() > 0
+	case reflect.Invalid:
+		return false
+	default:
+		return !fv.IsZero()
+	}
+}
+
+var builtinRules = map[string]ValidationFunc{
+	"min":    isMin,
+	"max":    isMax,
+	"len":    isLen,
+	"oneof":  isOneOf,
+	"email":  isEmail,
+	"regexp": isMatch,
+}
+
+// number returns the number the size rules compare: the value of numbers and
+// the length of strings, slices, arrays and maps.
+func number(fv reflect.Value) (float64, bool) {
+	switch fv.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(fv.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(fv.Len()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(fv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(fv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return fv.Float(), true
+	}
+	return 0, false
+}
+
+func compareParam(fv reflect.Value, param string, cmp func(n, p float64) bool) bool {
+	n, ok := number(fv)
+	if !ok {
+		return false
+	}
+	p, err := strconv.ParseFloat(param, 64)
+	assert1(err == nil, "invalid validation param '"+param+"'")
+	return cmp(n, p)
+}
+
+func isMin(fv reflect.Value, param string) bool {
+	return compareParam(fv, param, func(n, p float64) bool { return n >= p })
+}
+
+func isMax(fv reflect.Value, param string) bool {
+	return compareParam(fv, param, func(n, p float64) bool { return n <= p })
+}
+
+func isLen(fv reflect.Value, param string) bool {
+	return compareParam(fv, param, func(n, p float64) bool { return n == p })
+}
+
+func isOneOf(fv reflect.Value, param string) bool {
+	var s string
+	switch fv.Kind() {
+	case reflect.String:
+		s = fv.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s = strconv.FormatInt(fv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s = strconv.FormatUint(fv.Uint(), 10)
+	default:
+		return false
+	}
+
+	for _, opt := range strings.Fields(param) {
+		if s == opt {
+			return true
+		}
+	}
+	return false
+}
+
+var emailRegexp = regexp.MustCompile(`^[a-zA-Z0-9.!#$%&'*+/=?^_{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$`)
+
+func isEmail(fv reflect.Value, _ string) bool {
+	return fv.Kind() == reflect.String && emailRegexp.MatchString(fv.String())
+}
+
+var regexpCache sync.Map // string -> *regexp.Regexp
+
+func compileRegexp(expr string) (*regexp.Regexp, error) {
+	if re, ok := regexpCache.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	regexpCache.Store(expr, re)
+	return re, nil
+}
+
+func isMatch(fv reflect.Value, param string) bool {
+	if fv.Kind() != reflect.String {
+		return false
+	}
+	re, err := compileRegexp(param)
+	return err == nil && re.MatchString(fv.String())
+}
@@ -0,0 +1,58 @@
+// Copyright 2018 Yusan Kurban. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package klyn
+
+import (
+	"strings"
+	"testing"
+)
+
+type badRuleRequest struct {
+	Name string `validate:"required,mni=3"`
+}
+
+func TestValidateUndefinedRule(t *testing.T) {
+	err := NewValidator().ValidateStruct(&badRuleRequest{Name: "abc"})
+	if err == nil || !strings.Contains(err.Error(), "undefined validation rule 'mni'") {
+		t.Fatalf("got %v, want undefined rule error", err)
+	}
+	if _, ok := err.(ValidationErrors); ok {
+		t.Errorf("got ValidationErrors, want a tag error")
+	}
+}
+
+func TestValidateInvalidParam(t *testing.T) {
+	tests := []interface{}{
+		&struct {
+			Name string `validate:"min=abc"`
+		}{Name: "abc"},
+		&struct {
+			Age int `validate:"max="`
+		}{Age: 1},
+		&struct {
+			Code string `validate:"len=3x"`
+		}{Code: "abc"},
+		&struct {
+			Code string `validate:"regexp=[a-"`
+		}{Code: "abc"},
+	}
+	for _, obj := range tests {
+		err := NewValidator().ValidateStruct(obj)
+		if err == nil || !strings.Contains(err.Error(), "invalid validation") {
+			t.Errorf("%T: got %v, want invalid param error", obj, err)
+		}
+	}
+}
+
+func TestValidateTypedRouteOnRegister(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(error).Error(), "mni") {
+			t.Errorf("got %v, want panic on registration", r)
+		}
+	}()
+
+	core := New()
+	core.POST("/", Typed(func(c *Context, req badRuleRequest) (string, error) { return "", nil }))
+}